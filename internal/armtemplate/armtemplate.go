@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/magodo/armid"
 	"github.com/magodo/aztft/aztft"
 )
 
@@ -27,6 +28,20 @@ type ResourceId struct {
 
 var ResourceGroupId = ResourceId{}
 
+// QueryTypeAndId queries the TF resource types and ids of an Azure resource id. It is a variable so that it can be
+// replaced by the recorded results in the offline tests, as the query might call the Azure API.
+var QueryTypeAndId = aztft.QueryTypeAndId
+
+// ArmTypeOfId returns the ARM resource type (e.g. "Microsoft.Network/virtualNetworks/subnets") of the Azure resource
+// id. It returns an empty string if the id is not a valid Azure resource id.
+func ArmTypeOfId(azureId string) string {
+	id, err := armid.ParseResourceId(azureId)
+	if err != nil {
+		return ""
+	}
+	return strings.Join(append([]string{id.Provider()}, id.Types()...), "/")
+}
+
 func ParseResourceId(id string) (*ResourceId, error) {
 	id = strings.TrimPrefix(id, "/")
 	id = strings.TrimSuffix(id, "/")
@@ -118,8 +133,25 @@ func (res ResourceId) ID(sub, rg string) string {
 	return strings.Join(segs, "/")
 }
 
+// CallExpr converts the ARM ResourceId to its "resourceId()" call expression, e.g. "[resourceId('Microsoft.Network/virtualNetworks/subnets', 'vnet', 'subnet')]".
+func (res ResourceId) CallExpr() string {
+	names := strings.Split(res.Name, "/")
+	for i, n := range names {
+		names[i] = "'" + n + "'"
+	}
+	return fmt.Sprintf("[resourceId('%s', %s)]", res.Type, strings.Join(names, ", "))
+}
+
 type ResourceIds []ResourceId
 
+func (resids ResourceIds) MarshalJSON() ([]byte, error) {
+	residExprs := []string{}
+	for _, id := range resids {
+		residExprs = append(residExprs, id.CallExpr())
+	}
+	return json.Marshal(residExprs)
+}
+
 func (resids *ResourceIds) UnmarshalJSON(b []byte) error {
 	var residExprs []string
 	if err := json.Unmarshal(b, &residExprs); err != nil {
@@ -149,7 +181,10 @@ type TFResource struct {
 	DependsOn  []string
 }
 
-func (tpl Template) ToTFResources(subId, rg string) TFResources {
+// ToTFResources converts the resources in the template to the TF resources. The allowAPI tells whether the Azure API
+// can be called to resolve the TF resource types and ids of the resources, which is necessary for some resources
+// (e.g. the key vault certificates).
+func (tpl Template) ToTFResources(subId, rg string, allowAPI bool) TFResources {
 	// A temporary mapping to map from the azure ID to TF ID. This mapping assumes that azure and TF resource has 1:1 mapping.
 	azToTf := map[string]string{}
 	tfresources := TFResources{}
@@ -161,7 +196,7 @@ func (tpl Template) ToTFResources(subId, rg string) TFResources {
 			tfId   = azureId
 			tfType string
 		)
-		tftypes, tfids, err := aztft.QueryTypeAndId(azureId, true)
+		tftypes, tfids, err := QueryTypeAndId(azureId, allowAPI)
 		if err == nil {
 			if len(tfids) == 1 && len(tftypes) == 1 {
 				tfId = tfids[0]
@@ -187,13 +222,21 @@ func (tpl Template) ToTFResources(subId, rg string) TFResources {
 		}
 	}
 
+	// Merging the dependencies inferred from the resource ids referenced in the properties.
+	inferDependencies(tfresources, subId, rg)
+
 	// Converting the DependsOn of each TFResource from Azure IDs to TF IDs.
 	for k, res := range tfresources {
 		dependsOn := []string{}
 		for _, azureId := range res.DependsOn {
-			// Every entry in the tfresoruces msut be recorded in the azToTf mapping, so no need to check existance here.
-			dependsOn = append(dependsOn, azToTf[azureId])
+			// The inferred dependencies might be beyond the export scope, which are kept as the Azure IDs.
+			if tfId, ok := azToTf[azureId]; ok {
+				dependsOn = append(dependsOn, tfId)
+			} else {
+				dependsOn = append(dependsOn, azureId)
+			}
 		}
+		res.DependsOn = dependsOn
 		tfresources[k] = res
 	}
 
@@ -0,0 +1,151 @@
+package armtemplate
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// Association is a TF association resource, which links two resources. It doesn't exist in the ARM template, but is
+// derived from the properties of the resource that holds the link (e.g. the network security group id of a subnet).
+type Association struct {
+	TFType string
+
+	// TFId is the id used to import the association resource, which is a composite id for some association types
+	// (e.g. "<nic id>|<nsg id>").
+	TFId string
+
+	// DependsOn are the TF ids of the linked resources, in the order of the holding resource first.
+	// For the linked resources beyond the template, their Azure ids are used instead.
+	DependsOn []string
+}
+
+type associationRule struct {
+	// The ARM type of the resource that holds the link.
+	armType string
+
+	// The TF type of the association resource.
+	tfType string
+
+	// The gjson path to the linked resource id, in the properties of the holding resource.
+	path string
+
+	// Whether the TF id of the association is in form of "<holding id>|<linked id>". Otherwise, it is the same as
+	// the holding resource.
+	composite bool
+}
+
+var associationRules = []associationRule{
+	{
+		armType: "Microsoft.Network/virtualNetworks/subnets",
+		tfType:  "azurerm_subnet_network_security_group_association",
+		path:    "networkSecurityGroup.id",
+	},
+	{
+		armType: "Microsoft.Network/virtualNetworks/subnets",
+		tfType:  "azurerm_subnet_route_table_association",
+		path:    "routeTable.id",
+	},
+	{
+		armType:   "Microsoft.Network/networkInterfaces",
+		tfType:    "azurerm_network_interface_security_group_association",
+		path:      "networkSecurityGroup.id",
+		composite: true,
+	},
+}
+
+// Associations detects the links among the resources from their properties, and returns the TF association
+// resources that model these links, sorted by the TF id and type.
+func (resources TFResources) Associations(subId, rg string) ([]Association, error) {
+	// The azure ids referenced in the properties are compared case insensitively.
+	azureIds := map[string]string{}
+	tfIds := map[string]string{}
+	for _, res := range resources {
+		azureIds[strings.ToLower(res.AzureId)] = res.AzureId
+		tfIds[res.AzureId] = res.TFId
+	}
+
+	// linkedId returns the Azure id of the linked resource, and the TF id (or Azure id for those beyond the template)
+	// of the resource it depends on.
+	linkedId := func(v string) (string, string, bool) {
+		id, ok := parseReferencedId(v, subId, rg)
+		if !ok {
+			return "", "", false
+		}
+		if azureId, ok := azureIds[strings.ToLower(id)]; ok {
+			return azureId, tfIds[azureId], true
+		}
+		// E.g. the backend address pool is not exported as a standalone resource, depend on its load balancer instead.
+		if azureId, ok := lookupAncestor(azureIds, id); ok {
+			return id, tfIds[azureId], true
+		}
+		return id, id, true
+	}
+
+	var out []Association
+	for _, res := range resources {
+		if res.Properties == nil {
+			continue
+		}
+		b, err := json.Marshal(res.Properties)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling the properties of %s: %v", res.AzureId, err)
+		}
+		rt := ArmTypeOfId(res.AzureId)
+
+		for _, rule := range associationRules {
+			if !strings.EqualFold(rule.armType, rt) {
+				continue
+			}
+			result := gjson.GetBytes(b, rule.path)
+			if result.Type != gjson.String {
+				continue
+			}
+			id, dep, ok := linkedId(result.String())
+			if !ok {
+				continue
+			}
+			assoc := Association{
+				TFType:    rule.tfType,
+				TFId:      res.TFId,
+				DependsOn: []string{res.TFId, dep},
+			}
+			if rule.composite {
+				assoc.TFId = res.TFId + "|" + id
+			}
+			out = append(out, assoc)
+		}
+
+		// The load balancer backend address pools are linked per IP configuration of the network interface.
+		if strings.EqualFold(rt, "Microsoft.Network/networkInterfaces") {
+			for _, ipConfig := range gjson.GetBytes(b, "ipConfigurations").Array() {
+				name := ipConfig.Get("name").String()
+				if name == "" {
+					continue
+				}
+				for _, pool := range ipConfig.Get("properties.loadBalancerBackendAddressPools.#.id").Array() {
+					id, dep, ok := linkedId(pool.String())
+					if !ok {
+						continue
+					}
+					out = append(out, Association{
+						TFType:    "azurerm_network_interface_backend_address_pool_association",
+						TFId:      res.TFId + "/ipConfigurations/" + name + "|" + id,
+						DependsOn: []string{res.TFId, dep},
+					})
+				}
+			}
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].TFId != out[j].TFId {
+			return out[i].TFId < out[j].TFId
+		}
+		return out[i].TFType < out[j].TFType
+	})
+	return out, nil
+}
@@ -0,0 +1,205 @@
+package armtemplate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// CompositionRules declares how the ARM resources compose the TF resources, in case they are not 1:1 mapped.
+type CompositionRules struct {
+	Merges []MergeRule
+	Splits []SplitRule
+}
+
+// MergeRule merges N ARM resources into 1 resource, which is mapped to 1 TF resource.
+// The resources of the source types that share the same name are merged, only when all the source types are present.
+type MergeRule struct {
+	// Sources are the ARM resource types to be merged.
+	Sources []string
+	// Target is the ARM resource type of the merged resource.
+	Target string
+}
+
+// SplitRule splits 1 ARM resource into N resources (including itself), each is mapped to 1 TF resource.
+type SplitRule struct {
+	// Source is the ARM resource type to be split.
+	Source string
+	// Targets are the resources split from the source resource, besides the source resource itself.
+	Targets []SplitTarget
+}
+
+type SplitTarget struct {
+	// Type is the ARM resource type of the split resource.
+	Type string
+	// NameSuffix is appended to the name of the source resource, as the name of the split resource (e.g. "/default").
+	NameSuffix string
+	// Path is the gjson path in the properties of the source resource. If specified, the split resource only exists
+	// when the path exists, and its properties are the value of that path.
+	Path string
+}
+
+// DefaultCompositionRules is the builtin composition rules.
+var DefaultCompositionRules = CompositionRules{
+	Merges: []MergeRule{
+		// KeyVault certificate is a special resource that its data plane entity is composed of two control plane resources.
+		// ARM template exports the control plane resource ids, while Terraform uses its data plane counterpart.
+		{
+			Sources: []string{"Microsoft.KeyVault/vaults/keys", "Microsoft.KeyVault/vaults/secrets"},
+			Target:  "Microsoft.KeyVault/vaults/certificates",
+		},
+	},
+}
+
+// Apply applies the merge rules, then the split rules on the resources.
+// The dependencies on the merged resources are redirected to the resulting resource.
+func (rules CompositionRules) Apply(resources []Resource) ([]Resource, error) {
+	for _, rule := range rules.Merges {
+		resources = rule.apply(resources)
+	}
+	for _, rule := range rules.Splits {
+		var err error
+		resources, err = rule.apply(resources)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resources, nil
+}
+
+func (rule MergeRule) apply(resources []Resource) []Resource {
+	sourceIndex := func(t string) int {
+		for i, st := range rule.Sources {
+			if strings.EqualFold(st, t) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	// Group the indexes of the resources to be merged by name.
+	groups := map[string][]int{}
+	for i, res := range resources {
+		if sourceIndex(res.Type) == -1 {
+			continue
+		}
+		groups[res.Name] = append(groups[res.Name], i)
+	}
+
+	// Key is the index of the first resource of each group to be merged, value is the merged resource.
+	merged := map[int]Resource{}
+	removed := map[int]bool{}
+	redirects := map[string]ResourceId{}
+	for name, idxs := range groups {
+		present := map[int]bool{}
+		for _, idx := range idxs {
+			present[sourceIndex(resources[idx].Type)] = true
+		}
+		if len(present) != len(rule.Sources) {
+			continue
+		}
+
+		target := Resource{
+			ResourceId: ResourceId{
+				Type: rule.Target,
+				Name: name,
+			},
+			DependsOn: ResourceIds{},
+		}
+		members := map[string]bool{}
+		for _, idx := range idxs {
+			members[resources[idx].key()] = true
+			redirects[resources[idx].key()] = target.ResourceId
+			removed[idx] = true
+		}
+		for _, idx := range idxs {
+			for _, dep := range resources[idx].DependsOn {
+				if members[(Resource{ResourceId: dep}).key()] {
+					continue
+				}
+				if !target.DependsOn.contains(dep) {
+					target.DependsOn = append(target.DependsOn, dep)
+				}
+			}
+		}
+		merged[idxs[0]] = target
+	}
+
+	var out []Resource
+	for i, res := range resources {
+		if target, ok := merged[i]; ok {
+			out = append(out, target)
+			continue
+		}
+		if removed[i] {
+			continue
+		}
+		out = append(out, res)
+	}
+	return redirectDependencies(out, redirects)
+}
+
+func (rule SplitRule) apply(resources []Resource) ([]Resource, error) {
+	var out []Resource
+	for _, res := range resources {
+		out = append(out, res)
+		if !strings.EqualFold(res.Type, rule.Source) {
+			continue
+		}
+		var b []byte
+		for _, target := range rule.Targets {
+			split := Resource{
+				ResourceId: ResourceId{
+					Type: target.Type,
+					Name: res.Name + target.NameSuffix,
+				},
+				DependsOn: ResourceIds{res.ResourceId},
+			}
+			if len(strings.Split(split.Type, "/"))-1 != len(strings.Split(split.Name, "/")) {
+				return nil, fmt.Errorf("splitting %q into %q: the resource name %q doesn't match the type", res.Type, target.Type, split.Name)
+			}
+			if target.Path != "" {
+				if b == nil {
+					var err error
+					b, err = json.Marshal(res.Properties)
+					if err != nil {
+						return nil, fmt.Errorf("marshaling %v: %v", res.Properties, err)
+					}
+				}
+				result := gjson.GetBytes(b, target.Path)
+				if !result.Exists() {
+					continue
+				}
+				split.Properties = result.Value()
+			}
+			out = append(out, split)
+		}
+	}
+	return out, nil
+}
+
+// redirectDependencies redirects the dependencies of the resources according to the redirects, which is keyed by the
+// resource key.
+func redirectDependencies(resources []Resource, redirects map[string]ResourceId) []Resource {
+	if len(redirects) == 0 {
+		return resources
+	}
+	for i, res := range resources {
+		if len(res.DependsOn) == 0 {
+			continue
+		}
+		deps := ResourceIds{}
+		for _, dep := range res.DependsOn {
+			if id, ok := redirects[(Resource{ResourceId: dep}).key()]; ok {
+				dep = id
+			}
+			if !deps.contains(dep) {
+				deps = append(deps, dep)
+			}
+		}
+		resources[i].DependsOn = deps
+	}
+	return resources
+}
@@ -0,0 +1,215 @@
+package armtemplate
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/magodo/armid"
+)
+
+// inferDependencies merges the dependencies that are inferred from the properties of each resource into its DependsOn.
+// The ARM template only records part of the dependencies in the "dependsOn", while many others only appear as the
+// resource ids referenced in the "properties" (e.g. the subnet id of a network interface).
+// The referenced ids are either in form of the "resourceId()" call expression (for resources within the export scope), or
+// the id literal (for resources beyond the export scope).
+//
+// Both the input and output DependsOn are Azure resource ids. The referenced resources that are not in the template are
+// mapped to their nearest ancestor in the template, if any. Otherwise, they are kept as is.
+// The references to the resource itself or its child resources are ignored, as are the ones that would form a dependency cycle.
+func inferDependencies(resources TFResources, subId, rg string) {
+	// The azure ids referenced in the properties are compared case insensitively.
+	azureIds := map[string]string{}
+	for _, res := range resources {
+		azureIds[strings.ToLower(res.AzureId)] = res.AzureId
+	}
+
+	graph := map[string][]string{}
+	for _, res := range resources {
+		graph[res.AzureId] = append([]string{}, res.DependsOn...)
+	}
+
+	var keys []string
+	for k := range resources {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		res := resources[k]
+		existing := map[string]bool{}
+		for _, dep := range res.DependsOn {
+			existing[dep] = true
+		}
+		for _, ref := range referencedIds(res.Properties, subId, rg) {
+			dep := ref
+			if id, ok := lookupAncestor(azureIds, ref); ok {
+				dep = id
+			}
+			if existing[dep] || isSelfOrDescendant(res.AzureId, dep) {
+				continue
+			}
+			if reachable(graph, dep, res.AzureId) {
+				log.Printf("Ignoring the dependency from %s to %s inferred from properties, as it forms a dependency cycle.\n", res.AzureId, dep)
+				continue
+			}
+			existing[dep] = true
+			res.DependsOn = append(res.DependsOn, dep)
+			graph[res.AzureId] = append(graph[res.AzureId], dep)
+		}
+		resources[k] = res
+	}
+}
+
+// referencedIds returns the sorted Azure resource ids referenced in the properties.
+func referencedIds(properties interface{}, subId, rg string) []string {
+	set := map[string]bool{}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case string:
+			if id, ok := parseReferencedId(v, subId, rg); ok {
+				set[id] = true
+			}
+		case []interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		case map[string]interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		}
+	}
+	walk(properties)
+
+	var ids []string
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func parseReferencedId(v, subId, rg string) (string, bool) {
+	if strings.HasPrefix(v, "[resourceId(") {
+		id, err := ParseResourceIdFromCallExpr(v)
+		if err != nil {
+			return "", false
+		}
+		// The call expression might specify the subscription id and/or the resource group name, which is not supported.
+		typeSegs := strings.Split(id.Type, "/")
+		if len(typeSegs) < 2 || len(typeSegs)-1 != len(strings.Split(id.Name, "/")) {
+			return "", false
+		}
+		return id.ID(subId, rg), true
+	}
+	if strings.HasPrefix(strings.ToLower(v), "/subscriptions/") {
+		id, err := armid.ParseResourceId(v)
+		if err != nil {
+			return "", false
+		}
+		// Only resources reside in a resource group are taken into consideration.
+		if _, ok := id.(*armid.ScopedResourceId); !ok {
+			return "", false
+		}
+		if _, ok := id.RootScope().(*armid.ResourceGroup); !ok {
+			return "", false
+		}
+		return v, true
+	}
+	return "", false
+}
+
+// lookupAncestor looks up the id, or its nearest ancestor resource id, in the azure ids.
+func lookupAncestor(azureIds map[string]string, id string) (string, bool) {
+	segs := strings.Split(strings.TrimSuffix(strings.ToLower(id), "/"), "/")
+	// A resource id within a resource group has at least 9 segments (including the leading empty one), e.g.
+	// /subscriptions/<sub>/resourceGroups/<rg>/providers/<provider>/<type>/<name>
+	for ; len(segs) >= 9; segs = segs[:len(segs)-2] {
+		if v, ok := azureIds[strings.Join(segs, "/")]; ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func isSelfOrDescendant(self, id string) bool {
+	self, id = strings.ToLower(self), strings.ToLower(id)
+	return id == self || strings.HasPrefix(id, self+"/")
+}
+
+// reachable tells whether the "to" is reachable from the "from" in the dependency graph.
+func reachable(graph map[string][]string, from, to string) bool {
+	visited := map[string]bool{}
+	var visit func(n string) bool
+	visit = func(n string) bool {
+		if n == to {
+			return true
+		}
+		if visited[n] {
+			return false
+		}
+		visited[n] = true
+		for _, next := range graph[n] {
+			if visit(next) {
+				return true
+			}
+		}
+		return false
+	}
+	return visit(from)
+}
+
+// SortByDependency sorts the TF resource ids in topological order, where the dependencies come before their dependents.
+// Only the dependencies among the specified ids are taken into consideration. The relative order of the input ids is
+// preserved as long as the dependencies allow, so that the output is deterministic. The ids that are involved in a
+// dependency cycle (if any) are appended at last, in their input order.
+func (resources TFResources) SortByDependency(ids []string) []string {
+	index := map[string]int{}
+	for i, id := range ids {
+		index[id] = i
+	}
+
+	indegree := make([]int, len(ids))
+	dependents := make([][]int, len(ids))
+	for i, id := range ids {
+		deps := map[int]bool{}
+		for _, dep := range resources[id].DependsOn {
+			if j, ok := index[dep]; ok && j != i && !deps[j] {
+				deps[j] = true
+				indegree[i]++
+				dependents[j] = append(dependents[j], i)
+			}
+		}
+	}
+
+	// The ready queue is kept sorted by the input index.
+	var ready []int
+	for i := range ids {
+		if indegree[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	done := make([]bool, len(ids))
+	var out []string
+	for len(ready) != 0 {
+		i := ready[0]
+		ready = ready[1:]
+		done[i] = true
+		out = append(out, ids[i])
+		for _, j := range dependents[i] {
+			indegree[j]--
+			if indegree[j] == 0 {
+				ready = append(ready, j)
+				sort.Ints(ready)
+			}
+		}
+	}
+	for i, id := range ids {
+		if !done[i] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
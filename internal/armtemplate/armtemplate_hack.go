@@ -3,21 +3,39 @@ package armtemplate
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"strings"
 
 	"github.com/tidwall/gjson"
 )
 
+// PropertiesGetter gets the properties of the resource, via the specified API version.
+type PropertiesGetter func(id ResourceId, apiVersion string) (interface{}, error)
+
+type TweakOptions struct {
+	// The subscription id and resource group name of the export scope.
+	SubscriptionId string
+	ResourceGroup  string
+
+	// ManagedResources is the managed resource table. The builtin table is used if it is nil.
+	ManagedResources ManagedResourceTable
+
+	// GetProperties is used to get the properties of the populated managed resources, in order to recursively populate
+	// the managed resources of them. Recursion is disabled if it is nil.
+	GetProperties PropertiesGetter
+}
+
 // TweakResources tweaks the resource set exported from ARM template, due to Terraform models the resources differently.
-func (tpl *Template) TweakResources() error {
-	// KeyVault certificate is a special resource that its data plane entity is composed of two control plane resources.
-	// ARM template exports the control plane resource ids, while Terraform uses its data plane counterpart.
-	if err := tpl.tweakForKeyVaultCertificate(); err != nil {
+func (tpl *Template) TweakResources(opts TweakOptions) error {
+	// Compose the resources that are not 1:1 mapped between ARM and Terraform.
+	resources, err := DefaultCompositionRules.Apply(tpl.Resources)
+	if err != nil {
 		return err
 	}
+	tpl.Resources = resources
 
 	// Populate exclusively managed resources that are missing from ARM template.
-	if err := tpl.populateManagedResources(); err != nil {
+	if err := tpl.populateManagedResources(opts); err != nil {
 		return err
 	}
 
@@ -43,66 +61,66 @@ func (tpl *Template) TweakResources() error {
 	return nil
 }
 
-func (tpl *Template) tweakForKeyVaultCertificate() error {
-	newResoruces := []Resource{}
-	pending := map[string]Resource{}
+func (tpl *Template) populateManagedResources(opts TweakOptions) error {
+	table := opts.ManagedResources
+	if table == nil {
+		table = DefaultManagedResourceTable()
+	}
+
+	existing := map[string]bool{}
 	for _, res := range tpl.Resources {
-		if res.Type != "Microsoft.KeyVault/vaults/keys" && res.Type != "Microsoft.KeyVault/vaults/secrets" {
-			newResoruces = append(newResoruces, res)
+		existing[res.key()] = true
+	}
+
+	// The populated resources are appended to the resource list, so that they are iterated as well, in order to
+	// recursively populate their managed resources.
+	resources := append([]Resource{}, tpl.Resources...)
+	for i := 0; i < len(resources); i++ {
+		res := resources[i]
+		_, entry, ok := table.lookup(res.Type)
+		if !ok || len(entry.Paths) == 0 {
 			continue
 		}
-		if _, ok := pending[res.Name]; !ok {
-			pending[res.Name] = res
-			continue
+		ids, err := managedResourceIds(res, opts.SubscriptionId, opts.ResourceGroup, entry.Paths...)
+		if err != nil {
+			return fmt.Errorf(`populating managed resources for %q: %v`, res.Type, err)
 		}
-		delete(pending, res.Name)
-		newResoruces = append(newResoruces, Resource{
-			ResourceId: ResourceId{
-				Type: "Microsoft.KeyVault/vaults/certificates",
-				Name: res.Name,
-			},
-			Properties: nil,
-			DependsOn:  res.DependsOn,
-		})
-	}
-	for _, res := range pending {
-		newResoruces = append(newResoruces, res)
-	}
-	tpl.Resources = newResoruces
-	return nil
-}
-
-func (tpl *Template) populateManagedResources() error {
-	newResoruces := []Resource{}
-	knownManagedResourceTypes := map[string][]string{
-		"Microsoft.Compute/virtualMachines": {
-			"storageProfile.dataDisks.#.managedDisk.id",
-		},
-	}
-	for _, res := range tpl.Resources {
-		if paths, ok := knownManagedResourceTypes[res.Type]; ok {
-			res, resources, err := populateManagedResourcesByPath(res, paths...)
-			if err != nil {
-				return fmt.Errorf(`populating managed resources for %q: %v`, res.Type, err)
+		for _, id := range ids {
+			mres := Resource{
+				ResourceId: id,
+				DependsOn:  []ResourceId{},
+			}
+			if !existing[mres.key()] {
+				if _, mentry, ok := table.lookup(id.Type); ok && opts.GetProperties != nil && mentry.APIVersion != "" && len(mentry.Paths) != 0 {
+					props, err := opts.GetProperties(id, mentry.APIVersion)
+					if err != nil {
+						// A single failure shouldn't fail the whole discovery, e.g. the managed resource is being deleted.
+						log.Printf("Skipping the managed resource %s as failed to get its properties: %v\n", id.ID(opts.SubscriptionId, opts.ResourceGroup), err)
+						continue
+					}
+					mres.Properties = props
+				}
+				existing[mres.key()] = true
+				resources = append(resources, mres)
+			}
+			if !res.DependsOn.contains(id) {
+				res.DependsOn = append(res.DependsOn, id)
 			}
-			newResoruces = append(newResoruces, *res)
-			newResoruces = append(newResoruces, resources...)
-		} else {
-			newResoruces = append(newResoruces, res)
 		}
+		resources[i] = res
 	}
-	tpl.Resources = newResoruces
+	tpl.Resources = resources
 	return nil
 }
 
-// populateManagedResourcesByPath populate the managed resources in the specified paths.
-// It will also update the specified resource's dependency accordingly.
-func populateManagedResourcesByPath(res Resource, paths ...string) (*Resource, []Resource, error) {
+// managedResourceIds returns the ids of the managed resources in the specified paths of the resource's properties.
+// Only the resources within the export scope are returned.
+func managedResourceIds(res Resource, subId, rg string, paths ...string) ([]ResourceId, error) {
 	b, err := json.Marshal(res.Properties)
 	if err != nil {
-		return nil, nil, fmt.Errorf("marshaling %v: %v", res.Properties, err)
+		return nil, fmt.Errorf("marshaling %v: %v", res.Properties, err)
 	}
-	var resources []Resource
+	var ids []ResourceId
 	for _, path := range paths {
 		result := gjson.GetBytes(b, path)
 		if !result.Exists() {
@@ -112,30 +130,39 @@ func populateManagedResourcesByPath(res Resource, paths ...string) (*Resource, [
 		for _, exprResult := range result.Array() {
 			// ARM template export ids in two forms:
 			// - Call expression: [resourceids(type, args)]. This is for resources within current export scope.
-			// - Id literal: This is for resources beyond current export scope .
-			if !strings.HasPrefix(exprResult.String(), "[") {
+			// - Id literal: This is for resources beyond current export scope. While the properties got from the API
+			//   always use the id literal, which might be within current export scope.
+			v := exprResult.String()
+			if strings.HasPrefix(v, "[") {
+				id, err := ParseResourceIdFromCallExpr(v)
+				if err != nil {
+					return nil, err
+				}
+				ids = append(ids, *id)
 				continue
 			}
-			id, err := ParseResourceIdFromCallExpr(exprResult.String())
-			if err != nil {
-				return nil, nil, err
+			id, err := ParseResourceId(v)
+			if err != nil || id.Type == "" {
+				continue
 			}
-
-			// Ideally, we should recursively export ARM template for this resource, fill in its properties
-			// and populate any managed resources within it, unless it has already exported.
-			// But here, as we explicitly pick up the managed resource to be populated, which means it is rarely possible that
-			// these resource are exported by the ARM template.
-			// TODO: needs to recursively populate these resources?
-			mres := Resource{
-				ResourceId: ResourceId{
-					Type: id.Type,
-					Name: id.Name,
-				},
-				DependsOn: []ResourceId{},
+			if subId == "" || !strings.EqualFold(id.ID(subId, rg), v) {
+				continue
 			}
-			res.DependsOn = append(res.DependsOn, mres.ResourceId)
-			resources = append(resources, mres)
+			ids = append(ids, *id)
+		}
+	}
+	return ids, nil
+}
+
+func (res Resource) key() string {
+	return strings.ToLower(res.Type + "|" + res.Name)
+}
+
+func (ids ResourceIds) contains(id ResourceId) bool {
+	for _, e := range ids {
+		if strings.EqualFold(e.Type, id.Type) && strings.EqualFold(e.Name, id.Name) {
+			return true
 		}
 	}
-	return &res, resources, nil
+	return false
 }
@@ -0,0 +1,31 @@
+package armtemplate
+
+// MergeTemplates merges the partial templates (e.g. exported in chunks) into one. The resources that appear in
+// multiple templates (e.g. a child resource exported together with its parent in different chunks) are merged into
+// one, whose dependencies are the union of them all, and whose properties are the first non-nil one.
+// The order of the resources is the order of their first appearance.
+func MergeTemplates(tpls ...Template) Template {
+	var out Template
+	index := map[string]int{}
+	for _, tpl := range tpls {
+		for _, res := range tpl.Resources {
+			i, ok := index[res.key()]
+			if !ok {
+				index[res.key()] = len(out.Resources)
+				res.DependsOn = append(ResourceIds{}, res.DependsOn...)
+				out.Resources = append(out.Resources, res)
+				continue
+			}
+			merged := &out.Resources[i]
+			if merged.Properties == nil {
+				merged.Properties = res.Properties
+			}
+			for _, dep := range res.DependsOn {
+				if !merged.DependsOn.contains(dep) {
+					merged.DependsOn = append(merged.DependsOn, dep)
+				}
+			}
+		}
+	}
+	return out
+}
@@ -2,6 +2,10 @@ package armtemplate_test
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/Azure/aztfy/internal/armtemplate"
@@ -327,3 +331,715 @@ func TestUnmarshalTemplate(t *testing.T) {
 		require.Equal(t, c.expect, out, c.name)
 	}
 }
+
+func TestToTFResourcesDependency(t *testing.T) {
+	input := `
+{
+	"resources": [
+		{
+			"type": "Microsoft.Network/virtualNetworks",
+			"name": "vnet",
+			"properties": {
+				"subnets": [
+					{
+						"id": "[resourceId('Microsoft.Network/virtualNetworks/subnets', 'vnet', 'subnet')]"
+					}
+				]
+			}
+		},
+		{
+			"type": "Microsoft.Network/virtualNetworks/subnets",
+			"name": "vnet/subnet",
+			"dependsOn": [
+				"[resourceId('Microsoft.Network/virtualNetworks', 'vnet')]",
+				"[resourceId('Microsoft.Network/networkSecurityGroups', 'nsg')]"
+			]
+		},
+		{
+			"type": "Microsoft.Network/networkSecurityGroups",
+			"name": "nsg",
+			"properties": {
+				"subnets": [
+					{
+						"id": "[resourceId('Microsoft.Network/virtualNetworks/subnets', 'vnet', 'subnet')]"
+					}
+				]
+			}
+		},
+		{
+			"type": "Microsoft.Network/loadBalancers",
+			"name": "lb"
+		},
+		{
+			"type": "Microsoft.Network/networkInterfaces",
+			"name": "nic",
+			"properties": {
+				"ipConfigurations": [
+					{
+						"id": "[concat(resourceId('Microsoft.Network/networkInterfaces', 'nic'), '/ipConfigurations/ipconfig1')]",
+						"properties": {
+							"subnet": {
+								"id": "[resourceId('Microsoft.Network/virtualNetworks/subnets', 'vnet', 'subnet')]"
+							},
+							"publicIPAddress": {
+								"id": "/subscriptions/sub1/resourceGroups/other/providers/Microsoft.Network/publicIPAddresses/pip"
+							},
+							"loadBalancerBackendAddressPools": [
+								{
+									"id": "[resourceId('Microsoft.Network/loadBalancers/backendAddressPools', 'lb', 'pool')]"
+								}
+							]
+						}
+					}
+				]
+			}
+		}
+	]
+}
+`
+	var tpl armtemplate.Template
+	require.NoError(t, json.Unmarshal([]byte(input), &tpl))
+
+	const prefix = "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Network"
+	expect := map[string][]string{
+		prefix + "/virtualNetworks/vnet":                {},
+		prefix + "/virtualNetworks/vnet/subnets/subnet": {prefix + "/virtualNetworks/vnet", prefix + "/networkSecurityGroups/nsg"},
+		// The inferred dependency on the subnet is ignored, as the subnet explicitly depends on the NSG.
+		prefix + "/networkSecurityGroups/nsg": {},
+		prefix + "/loadBalancers/lb":          {},
+		prefix + "/networkInterfaces/nic": {
+			"/subscriptions/sub1/resourceGroups/other/providers/Microsoft.Network/publicIPAddresses/pip",
+			prefix + "/loadBalancers/lb",
+			prefix + "/virtualNetworks/vnet/subnets/subnet",
+		},
+	}
+
+	resources := tpl.ToTFResources("sub1", "rg1", false)
+	actual := map[string][]string{}
+	for _, res := range resources {
+		actual[res.AzureId] = res.DependsOn
+	}
+	require.Equal(t, expect, actual)
+}
+
+func TestSortByDependency(t *testing.T) {
+	resources := armtemplate.TFResources{
+		"a": {TFId: "a"},
+		"b": {TFId: "b", DependsOn: []string{"c"}},
+		"c": {TFId: "c", DependsOn: []string{"a", "out-of-scope"}},
+		"d": {TFId: "d"},
+		"x": {TFId: "x", DependsOn: []string{"y"}},
+		"y": {TFId: "y", DependsOn: []string{"x"}},
+	}
+	cases := []struct {
+		name   string
+		input  []string
+		expect []string
+	}{
+		{
+			name:   "no dependency",
+			input:  []string{"d", "a"},
+			expect: []string{"d", "a"},
+		},
+		{
+			name:   "dependency chain",
+			input:  []string{"a", "b", "c", "d"},
+			expect: []string{"a", "c", "b", "d"},
+		},
+		{
+			name:   "dependency not in the input",
+			input:  []string{"b", "c"},
+			expect: []string{"c", "b"},
+		},
+		{
+			name:   "cycle",
+			input:  []string{"x", "y", "a"},
+			expect: []string{"a", "x", "y"},
+		},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.expect, resources.SortByDependency(c.input), c.name)
+	}
+}
+
+// testManagedResourceTable extends the builtin table, to recursively populate the NIC and its public IP of a VM.
+var testManagedResourceTable = armtemplate.ManagedResourceTable{
+	"Microsoft.Compute/virtualMachines": {
+		APIVersion: "2022-03-01",
+		Paths: []string{
+			"storageProfile.osDisk.managedDisk.id",
+			"storageProfile.dataDisks.#.managedDisk.id",
+			"networkProfile.networkInterfaces.#.id",
+		},
+	},
+	"Microsoft.Compute/disks": {
+		APIVersion: "2022-03-02",
+		Paths:      []string{},
+	},
+	"Microsoft.Network/networkInterfaces": {
+		APIVersion: "2021-08-01",
+		Paths:      []string{"ipConfigurations.#.properties.publicIPAddress.id"},
+	},
+	"Microsoft.Network/publicIPAddresses": {
+		APIVersion: "2021-08-01",
+		Paths:      []string{},
+	},
+}
+
+const testVMTemplate = `
+{
+	"resources": [
+		{
+			"type": "Microsoft.Compute/virtualMachines",
+			"name": "vm",
+			"properties": {
+				"storageProfile": {
+					"osDisk": {
+						"managedDisk": {
+							"id": "[resourceId('Microsoft.Compute/disks', 'osdisk')]"
+						}
+					},
+					"dataDisks": [
+						{
+							"managedDisk": {
+								"id": "/subscriptions/sub1/resourceGroups/other/providers/Microsoft.Compute/disks/datadisk"
+							}
+						}
+					]
+				},
+				"networkProfile": {
+					"networkInterfaces": [
+						{
+							"id": "[resourceId('Microsoft.Network/networkInterfaces', 'nic')]"
+						}
+					]
+				}
+			}
+		}
+	]
+}
+`
+
+func TestTweakResourcesPopulateManagedResources(t *testing.T) {
+	var tpl armtemplate.Template
+	require.NoError(t, json.Unmarshal([]byte(testVMTemplate), &tpl))
+
+	var fetched []string
+	opts := armtemplate.TweakOptions{
+		SubscriptionId:   "sub1",
+		ResourceGroup:    "rg1",
+		ManagedResources: testManagedResourceTable,
+		GetProperties: func(id armtemplate.ResourceId, apiVersion string) (interface{}, error) {
+			fetched = append(fetched, id.ID("sub1", "rg1"))
+			var props interface{}
+			err := json.Unmarshal([]byte(`{
+	"ipConfigurations": [
+		{
+			"properties": {
+				"publicIPAddress": {
+					"id": "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Network/publicIPAddresses/pip"
+				}
+			}
+		}
+	]
+}`), &props)
+			return props, err
+		},
+	}
+	require.NoError(t, tpl.TweakResources(opts))
+
+	// Only the NIC is fetched, as the managed disk has no managed resources.
+	require.Equal(t, []string{"/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Network/networkInterfaces/nic"}, fetched)
+
+	var actual []armtemplate.Resource
+	for _, res := range tpl.Resources {
+		actual = append(actual, armtemplate.Resource{ResourceId: res.ResourceId, DependsOn: res.DependsOn})
+	}
+	require.Equal(t, []armtemplate.Resource{
+		{
+			ResourceId: armtemplate.ResourceId{Type: "Microsoft.Compute/virtualMachines", Name: "vm"},
+			DependsOn: armtemplate.ResourceIds{
+				{Type: "Microsoft.Compute/disks", Name: "osdisk"},
+				{Type: "Microsoft.Network/networkInterfaces", Name: "nic"},
+			},
+		},
+		{
+			ResourceId: armtemplate.ResourceId{Type: "Microsoft.Compute/disks", Name: "osdisk"},
+			DependsOn:  armtemplate.ResourceIds{armtemplate.ResourceGroupId},
+		},
+		{
+			ResourceId: armtemplate.ResourceId{Type: "Microsoft.Network/networkInterfaces", Name: "nic"},
+			DependsOn: armtemplate.ResourceIds{
+				{Type: "Microsoft.Network/publicIPAddresses", Name: "pip"},
+			},
+		},
+		{
+			ResourceId: armtemplate.ResourceId{Type: "Microsoft.Network/publicIPAddresses", Name: "pip"},
+			DependsOn:  armtemplate.ResourceIds{armtemplate.ResourceGroupId},
+		},
+		{
+			ResourceId: armtemplate.ResourceGroupId,
+			DependsOn:  armtemplate.ResourceIds{},
+		},
+	}, actual)
+}
+
+func TestLoadManagedResourceTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "table.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+	"microsoft.compute/virtualmachines": {
+		"paths": []
+	},
+	"Microsoft.Foo/bars": {
+		"paths": ["bazId"]
+	}
+}`), 0644))
+	table, err := armtemplate.LoadManagedResourceTable(path)
+	require.NoError(t, err)
+
+	_, ok := table["Microsoft.Compute/virtualMachines"]
+	require.False(t, ok)
+	require.Equal(t, armtemplate.ManagedResourceTableEntry{Paths: []string{}}, table["microsoft.compute/virtualmachines"])
+	require.Equal(t, armtemplate.ManagedResourceTableEntry{Paths: []string{"bazId"}}, table["Microsoft.Foo/bars"])
+	require.Equal(t, armtemplate.DefaultManagedResourceTable()["Microsoft.Compute/disks"], table["Microsoft.Compute/disks"])
+}
+
+func TestCompositionRulesApply(t *testing.T) {
+	vault := armtemplate.ResourceId{Type: "Microsoft.KeyVault/vaults", Name: "kv"}
+	key := armtemplate.ResourceId{Type: "Microsoft.KeyVault/vaults/keys", Name: "kv/cert"}
+	secret := armtemplate.ResourceId{Type: "Microsoft.KeyVault/vaults/secrets", Name: "kv/cert"}
+	cert := armtemplate.ResourceId{Type: "Microsoft.KeyVault/vaults/certificates", Name: "kv/cert"}
+	site := armtemplate.ResourceId{Type: "Microsoft.Web/sites", Name: "site"}
+
+	splitRules := armtemplate.CompositionRules{
+		Splits: []armtemplate.SplitRule{
+			{
+				Source: "Microsoft.Web/sites",
+				Targets: []armtemplate.SplitTarget{
+					{
+						Type:       "Microsoft.Web/sites/config",
+						NameSuffix: "/web",
+					},
+					{
+						Type:       "Microsoft.Web/sites/sourcecontrols",
+						NameSuffix: "/web",
+						Path:       "siteConfig.scm",
+					},
+				},
+			},
+		},
+	}
+
+	cases := []struct {
+		name   string
+		rules  armtemplate.CompositionRules
+		input  []armtemplate.Resource
+		expect []armtemplate.Resource
+		err    bool
+	}{
+		{
+			name:  "merge",
+			rules: armtemplate.DefaultCompositionRules,
+			input: []armtemplate.Resource{
+				{ResourceId: vault},
+				{ResourceId: key, DependsOn: armtemplate.ResourceIds{vault}},
+				{ResourceId: site, DependsOn: armtemplate.ResourceIds{secret, key}},
+				{ResourceId: secret, DependsOn: armtemplate.ResourceIds{vault, key}},
+			},
+			expect: []armtemplate.Resource{
+				{ResourceId: vault},
+				{ResourceId: cert, DependsOn: armtemplate.ResourceIds{vault}},
+				{ResourceId: site, DependsOn: armtemplate.ResourceIds{cert}},
+			},
+		},
+		{
+			name:  "merge with missing source",
+			rules: armtemplate.DefaultCompositionRules,
+			input: []armtemplate.Resource{
+				{ResourceId: vault},
+				{ResourceId: key, DependsOn: armtemplate.ResourceIds{vault}},
+			},
+			expect: []armtemplate.Resource{
+				{ResourceId: vault},
+				{ResourceId: key, DependsOn: armtemplate.ResourceIds{vault}},
+			},
+		},
+		{
+			name:  "split without path",
+			rules: splitRules,
+			input: []armtemplate.Resource{
+				{ResourceId: site, Properties: map[string]interface{}{}},
+			},
+			expect: []armtemplate.Resource{
+				{ResourceId: site, Properties: map[string]interface{}{}},
+				{
+					ResourceId: armtemplate.ResourceId{Type: "Microsoft.Web/sites/config", Name: "site/web"},
+					DependsOn:  armtemplate.ResourceIds{site},
+				},
+			},
+		},
+		{
+			name:  "split with path",
+			rules: splitRules,
+			input: []armtemplate.Resource{
+				{ResourceId: site, Properties: map[string]interface{}{"siteConfig": map[string]interface{}{"scm": map[string]interface{}{"branch": "main"}}}},
+			},
+			expect: []armtemplate.Resource{
+				{ResourceId: site, Properties: map[string]interface{}{"siteConfig": map[string]interface{}{"scm": map[string]interface{}{"branch": "main"}}}},
+				{
+					ResourceId: armtemplate.ResourceId{Type: "Microsoft.Web/sites/config", Name: "site/web"},
+					DependsOn:  armtemplate.ResourceIds{site},
+				},
+				{
+					ResourceId: armtemplate.ResourceId{Type: "Microsoft.Web/sites/sourcecontrols", Name: "site/web"},
+					Properties: map[string]interface{}{"branch": "main"},
+					DependsOn:  armtemplate.ResourceIds{site},
+				},
+			},
+		},
+		{
+			name: "split with mismatched name",
+			rules: armtemplate.CompositionRules{
+				Splits: []armtemplate.SplitRule{
+					{
+						Source:  "Microsoft.Web/sites",
+						Targets: []armtemplate.SplitTarget{{Type: "Microsoft.Web/sites/config"}},
+					},
+				},
+			},
+			input: []armtemplate.Resource{{ResourceId: site}},
+			err:   true,
+		},
+	}
+
+	for _, c := range cases {
+		actual, err := c.rules.Apply(c.input)
+		if c.err {
+			require.Error(t, err, c.name)
+			continue
+		}
+		require.NoError(t, err, c.name)
+		require.Equal(t, c.expect, actual, c.name)
+	}
+}
+
+func TestAssociations(t *testing.T) {
+	const (
+		prefix   = "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Network"
+		subnetId = prefix + "/virtualNetworks/vnet/subnets/subnet"
+		nsgId    = prefix + "/networkSecurityGroups/nsg"
+		rtId     = "/subscriptions/sub1/resourceGroups/other/providers/Microsoft.Network/routeTables/rt"
+		nicId    = prefix + "/networkInterfaces/nic"
+		lbId     = prefix + "/loadBalancers/lb"
+		poolId   = lbId + "/backendAddressPools/pool"
+	)
+	resources := armtemplate.TFResources{
+		subnetId: {
+			AzureId: subnetId,
+			TFId:    subnetId,
+			Properties: map[string]interface{}{
+				"networkSecurityGroup": map[string]interface{}{
+					"id": "[resourceId('Microsoft.Network/networkSecurityGroups', 'nsg')]",
+				},
+				"routeTable": map[string]interface{}{
+					"id": rtId,
+				},
+			},
+		},
+		nsgId: {
+			AzureId: nsgId,
+			TFId:    nsgId,
+		},
+		lbId: {
+			AzureId: lbId,
+			TFId:    lbId,
+		},
+		nicId: {
+			AzureId: nicId,
+			TFId:    nicId,
+			Properties: map[string]interface{}{
+				"networkSecurityGroup": map[string]interface{}{
+					"id": strings.ToUpper(nsgId),
+				},
+				"ipConfigurations": []interface{}{
+					map[string]interface{}{
+						"name": "ipconfig1",
+						"properties": map[string]interface{}{
+							"loadBalancerBackendAddressPools": []interface{}{
+								map[string]interface{}{
+									"id": "[resourceId('Microsoft.Network/loadBalancers/backendAddressPools', 'lb', 'pool')]",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	actual, err := resources.Associations("sub1", "rg1")
+	require.NoError(t, err)
+	require.Equal(t, []armtemplate.Association{
+		{
+			TFType:    "azurerm_network_interface_backend_address_pool_association",
+			TFId:      nicId + "/ipConfigurations/ipconfig1|" + poolId,
+			DependsOn: []string{nicId, lbId},
+		},
+		{
+			TFType:    "azurerm_network_interface_security_group_association",
+			TFId:      nicId + "|" + nsgId,
+			DependsOn: []string{nicId, nsgId},
+		},
+		{
+			TFType:    "azurerm_subnet_network_security_group_association",
+			TFId:      subnetId,
+			DependsOn: []string{subnetId, nsgId},
+		},
+		{
+			TFType:    "azurerm_subnet_route_table_association",
+			TFId:      subnetId,
+			DependsOn: []string{subnetId, rtId},
+		},
+	}, actual)
+}
+
+func TestMergeTemplates(t *testing.T) {
+	var (
+		vnet   = armtemplate.ResourceId{Type: "Microsoft.Network/virtualNetworks", Name: "vnet"}
+		subnet = armtemplate.ResourceId{Type: "Microsoft.Network/virtualNetworks/subnets", Name: "vnet/subnet"}
+		nsg    = armtemplate.ResourceId{Type: "Microsoft.Network/networkSecurityGroups", Name: "nsg"}
+		nic    = armtemplate.ResourceId{Type: "Microsoft.Network/networkInterfaces", Name: "nic"}
+	)
+	cases := []struct {
+		name   string
+		input  []armtemplate.Template
+		expect armtemplate.Template
+	}{
+		{
+			name: "no template",
+		},
+		{
+			name: "disjoint templates",
+			input: []armtemplate.Template{
+				{Resources: []armtemplate.Resource{{ResourceId: vnet}, {ResourceId: subnet, DependsOn: armtemplate.ResourceIds{vnet}}}},
+				{Resources: []armtemplate.Resource{{ResourceId: nic, DependsOn: armtemplate.ResourceIds{subnet}}}},
+			},
+			expect: armtemplate.Template{
+				Resources: []armtemplate.Resource{
+					{ResourceId: vnet, DependsOn: armtemplate.ResourceIds{}},
+					{ResourceId: subnet, DependsOn: armtemplate.ResourceIds{vnet}},
+					{ResourceId: nic, DependsOn: armtemplate.ResourceIds{subnet}},
+				},
+			},
+		},
+		{
+			name: "overlapped templates",
+			input: []armtemplate.Template{
+				{Resources: []armtemplate.Resource{{ResourceId: vnet}, {ResourceId: subnet, DependsOn: armtemplate.ResourceIds{vnet}}}},
+				{
+					Resources: []armtemplate.Resource{
+						{ResourceId: nsg},
+						{
+							ResourceId: armtemplate.ResourceId{Type: "microsoft.network/virtualNetworks/subnets", Name: "vnet/subnet"},
+							Properties: map[string]interface{}{"addressPrefix": "10.0.0.0/24"},
+							DependsOn:  armtemplate.ResourceIds{vnet, nsg},
+						},
+					},
+				},
+			},
+			expect: armtemplate.Template{
+				Resources: []armtemplate.Resource{
+					{ResourceId: vnet, DependsOn: armtemplate.ResourceIds{}},
+					{
+						ResourceId: subnet,
+						Properties: map[string]interface{}{"addressPrefix": "10.0.0.0/24"},
+						DependsOn:  armtemplate.ResourceIds{vnet, nsg},
+					},
+					{ResourceId: nsg, DependsOn: armtemplate.ResourceIds{}},
+				},
+			},
+		},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.expect, armtemplate.MergeTemplates(c.input...), c.name)
+	}
+}
+
+func TestTemplateMarshalRoundTrip(t *testing.T) {
+	tpl := armtemplate.Template{
+		Resources: []armtemplate.Resource{
+			{
+				ResourceId: armtemplate.ResourceId{Type: "Microsoft.Network/virtualNetworks", Name: "vnet"},
+				Properties: map[string]interface{}{"addressSpace": map[string]interface{}{"addressPrefixes": []interface{}{"10.0.0.0/16"}}},
+				DependsOn:  armtemplate.ResourceIds{armtemplate.ResourceGroupId},
+			},
+			{
+				ResourceId: armtemplate.ResourceId{Type: "Microsoft.Network/virtualNetworks/subnets", Name: "vnet/subnet"},
+				DependsOn:  armtemplate.ResourceIds{{Type: "Microsoft.Network/virtualNetworks", Name: "vnet"}},
+			},
+			{
+				ResourceId: armtemplate.ResourceGroupId,
+				DependsOn:  armtemplate.ResourceIds{},
+			},
+		},
+	}
+	b, err := json.Marshal(tpl)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"dependsOn":["[resourceId('Microsoft.Network/virtualNetworks', 'vnet')]"]`)
+
+	var actual armtemplate.Template
+	require.NoError(t, json.Unmarshal(b, &actual))
+	// The empty DependsOn is omitted during marshalling.
+	tpl.Resources[2].DependsOn = nil
+	require.Equal(t, tpl, actual)
+}
+
+func TestTweakResourcesPopulateManagedResourcesGetPropertiesError(t *testing.T) {
+	var tpl armtemplate.Template
+	require.NoError(t, json.Unmarshal([]byte(testVMTemplate), &tpl))
+
+	opts := armtemplate.TweakOptions{
+		SubscriptionId:   "sub1",
+		ResourceGroup:    "rg1",
+		ManagedResources: testManagedResourceTable,
+		GetProperties: func(id armtemplate.ResourceId, apiVersion string) (interface{}, error) {
+			return nil, fmt.Errorf("not found")
+		},
+	}
+	// The NIC that fails to get the properties is skipped, instead of failing the whole discovery.
+	require.NoError(t, tpl.TweakResources(opts))
+
+	var actual []armtemplate.Resource
+	for _, res := range tpl.Resources {
+		actual = append(actual, armtemplate.Resource{ResourceId: res.ResourceId, DependsOn: res.DependsOn})
+	}
+	require.Equal(t, []armtemplate.Resource{
+		{
+			ResourceId: armtemplate.ResourceId{Type: "Microsoft.Compute/virtualMachines", Name: "vm"},
+			DependsOn: armtemplate.ResourceIds{
+				{Type: "Microsoft.Compute/disks", Name: "osdisk"},
+			},
+		},
+		{
+			ResourceId: armtemplate.ResourceId{Type: "Microsoft.Compute/disks", Name: "osdisk"},
+			DependsOn:  armtemplate.ResourceIds{armtemplate.ResourceGroupId},
+		},
+		{
+			ResourceId: armtemplate.ResourceGroupId,
+			DependsOn:  armtemplate.ResourceIds{},
+		},
+	}, actual)
+}
+
+func TestTweakResourcesPopulateManagedResourcesAKS(t *testing.T) {
+	// The kubelet identity and the outbound IPs of an AKS cluster, which are in the node resource group, except the
+	// user provided outbound IP prefix.
+	input := `
+{
+	"resources": [
+		{
+			"type": "Microsoft.ContainerService/managedClusters",
+			"name": "aks",
+			"properties": {
+				"nodeResourceGroup": "MC_rg1_aks_westeurope",
+				"identityProfile": {
+					"kubeletidentity": {
+						"resourceId": "/subscriptions/sub1/resourceGroups/MC_rg1_aks_westeurope/providers/Microsoft.ManagedIdentity/userAssignedIdentities/aks-agentpool"
+					}
+				},
+				"networkProfile": {
+					"loadBalancerProfile": {
+						"outboundIPs": {
+							"publicIPs": [
+								{
+									"id": "/subscriptions/sub1/resourceGroups/MC_rg1_aks_westeurope/providers/Microsoft.Network/publicIPAddresses/pip"
+								}
+							]
+						},
+						"outboundIPPrefixes": {
+							"publicIPPrefixes": [
+								{
+									"id": "/subscriptions/sub1/resourceGroups/rg1/providers/Microsoft.Network/publicIPPrefixes/prefix"
+								}
+							]
+						}
+					}
+				}
+			}
+		}
+	]
+}
+`
+	table := armtemplate.ManagedResourceTable{
+		"Microsoft.ContainerService/managedClusters": {
+			APIVersion: "2022-06-01",
+			Paths: []string{
+				"networkProfile.loadBalancerProfile.outboundIPs.publicIPs.#.id",
+				"networkProfile.loadBalancerProfile.outboundIPPrefixes.publicIPPrefixes.#.id",
+				"identityProfile.kubeletidentity.resourceId",
+			},
+		},
+	}
+
+	cases := []struct {
+		name   string
+		table  armtemplate.ManagedResourceTable
+		expect []armtemplate.Resource
+	}{
+		{
+			name: "builtin table",
+			expect: []armtemplate.Resource{
+				{
+					ResourceId: armtemplate.ResourceId{Type: "Microsoft.ContainerService/managedClusters", Name: "aks"},
+					DependsOn:  armtemplate.ResourceIds{armtemplate.ResourceGroupId},
+				},
+				{
+					ResourceId: armtemplate.ResourceGroupId,
+					DependsOn:  armtemplate.ResourceIds{},
+				},
+			},
+		},
+		{
+			name:  "custom table",
+			table: table,
+			expect: []armtemplate.Resource{
+				{
+					ResourceId: armtemplate.ResourceId{Type: "Microsoft.ContainerService/managedClusters", Name: "aks"},
+					DependsOn: armtemplate.ResourceIds{
+						{Type: "Microsoft.Network/publicIPPrefixes", Name: "prefix"},
+					},
+				},
+				{
+					ResourceId: armtemplate.ResourceId{Type: "Microsoft.Network/publicIPPrefixes", Name: "prefix"},
+					DependsOn:  armtemplate.ResourceIds{armtemplate.ResourceGroupId},
+				},
+				{
+					ResourceId: armtemplate.ResourceGroupId,
+					DependsOn:  armtemplate.ResourceIds{},
+				},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		var tpl armtemplate.Template
+		require.NoError(t, json.Unmarshal([]byte(input), &tpl), c.name)
+		opts := armtemplate.TweakOptions{
+			SubscriptionId:   "sub1",
+			ResourceGroup:    "rg1",
+			ManagedResources: c.table,
+			GetProperties: func(id armtemplate.ResourceId, apiVersion string) (interface{}, error) {
+				t.Fatalf("unexpected get properties of %s", id.ID("sub1", "rg1"))
+				return nil, nil
+			},
+		}
+		require.NoError(t, tpl.TweakResources(opts), c.name)
+
+		var actual []armtemplate.Resource
+		for _, res := range tpl.Resources {
+			actual = append(actual, armtemplate.Resource{ResourceId: res.ResourceId, DependsOn: res.DependsOn})
+		}
+		require.Equal(t, c.expect, actual, c.name)
+	}
+}
@@ -0,0 +1,66 @@
+package armtemplate
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+//go:embed managed_resources.json
+var defaultManagedResourceTable []byte
+
+// ManagedResourceTable records the resources that are exclusively managed by other resources, which are missing from
+// the ARM template. The key is the ARM resource type (case insensitive).
+type ManagedResourceTable map[string]ManagedResourceTableEntry
+
+type ManagedResourceTableEntry struct {
+	// APIVersion is used to get the properties of this type of resource, when it is populated as a managed resource.
+	// The properties are used to recursively populate the managed resources of this resource.
+	APIVersion string `json:"api_version,omitempty"`
+
+	// Paths are the gjson paths to the ids of the managed resources, in the properties of this type of resource.
+	Paths []string `json:"paths"`
+}
+
+// DefaultManagedResourceTable returns the builtin managed resource table.
+func DefaultManagedResourceTable() ManagedResourceTable {
+	var table ManagedResourceTable
+	if err := json.Unmarshal(defaultManagedResourceTable, &table); err != nil {
+		panic(fmt.Sprintf("unmarshalling the builtin managed resource table: %v", err))
+	}
+	return table
+}
+
+// LoadManagedResourceTable loads the managed resource table file, which overrides the builtin table per resource type.
+func LoadManagedResourceTable(path string) (ManagedResourceTable, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading the managed resource table file %s: %v", path, err)
+	}
+	var overrides ManagedResourceTable
+	if err := json.Unmarshal(b, &overrides); err != nil {
+		return nil, fmt.Errorf("unmarshalling the managed resource table file %s: %v", path, err)
+	}
+	table := DefaultManagedResourceTable()
+	for rt, entry := range overrides {
+		if k, _, ok := table.lookup(rt); ok {
+			delete(table, k)
+		}
+		table[rt] = entry
+	}
+	return table, nil
+}
+
+func (table ManagedResourceTable) lookup(rt string) (string, ManagedResourceTableEntry, bool) {
+	if entry, ok := table[rt]; ok {
+		return rt, entry, true
+	}
+	for k, entry := range table {
+		if strings.EqualFold(k, rt) {
+			return k, entry, true
+		}
+	}
+	return "", ManagedResourceTableEntry{}, false
+}
@@ -0,0 +1,359 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/AzureAD/microsoft-authentication-library-for-go/apps/confidential"
+)
+
+const (
+	// AuthDefault uses the DefaultAzureCredential, which tries the environment variables, the managed identity and the
+	// Azure CLI in order, while the provider picks its own authentication method.
+	AuthDefault = "default"
+	// AuthCLI authenticates via the Azure CLI.
+	AuthCLI = "cli"
+	// AuthMSI authenticates via the managed identity, which is user assigned if ARM_CLIENT_ID is set.
+	AuthMSI = "msi"
+	// AuthSPSecret authenticates via the service principal with a client secret.
+	AuthSPSecret = "sp-secret"
+	// AuthSPCert authenticates via the service principal with a client certificate.
+	AuthSPCert = "sp-cert"
+	// AuthOIDC authenticates via the service principal with an OIDC token (e.g. the workload identity).
+	AuthOIDC = "oidc"
+)
+
+// PossibleAuthValues returns the possible values of the authentication method.
+func PossibleAuthValues() []string {
+	return []string{AuthDefault, AuthCLI, AuthMSI, AuthSPSecret, AuthSPCert, AuthOIDC}
+}
+
+// authEnvVars are the environment variables that select the authentication method of the provider, which are unset
+// unless they are set by the selected method.
+var authEnvVars = []string{
+	"ARM_CLIENT_SECRET",
+	"ARM_CLIENT_CERTIFICATE_PATH",
+	"ARM_CLIENT_CERTIFICATE_PASSWORD",
+	"ARM_USE_MSI",
+	"ARM_USE_OIDC",
+	"ARM_OIDC_TOKEN",
+	"ARM_OIDC_TOKEN_FILE_PATH",
+	"ARM_OIDC_REQUEST_TOKEN",
+	"ARM_OIDC_REQUEST_URL",
+}
+
+// newCredential builds the credential of the authentication method, together with the environment variables for the
+// provider (see providerEnv), so that it authenticates in the same way.
+func newCredential(auth string, cloudCfg cloud.Configuration) (azcore.TokenCredential, map[string]string, error) {
+	env, err := providerEnv(auth)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tenantId := os.Getenv("ARM_TENANT_ID")
+	clientId := os.Getenv("ARM_CLIENT_ID")
+	clientOpt := policy.ClientOptions{Cloud: cloudCfg}
+
+	switch auth {
+	case AuthDefault:
+		// Maps the auth related environment variables used in the provider to what azidentity honors.
+		if v, ok := os.LookupEnv("ARM_TENANT_ID"); ok {
+			os.Setenv("AZURE_TENANT_ID", v)
+		}
+		if v, ok := os.LookupEnv("ARM_CLIENT_ID"); ok {
+			os.Setenv("AZURE_CLIENT_ID", v)
+		}
+		if v, ok := os.LookupEnv("ARM_CLIENT_SECRET"); ok {
+			os.Setenv("AZURE_CLIENT_SECRET", v)
+		}
+		if v, ok := os.LookupEnv("ARM_CLIENT_CERTIFICATE_PATH"); ok {
+			os.Setenv("AZURE_CLIENT_CERTIFICATE_PATH", v)
+		}
+		cred, err := azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+			ClientOptions: clientOpt,
+			TenantID:      tenantId,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return cred, env, nil
+
+	case AuthCLI:
+		cred, err := azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{
+			TenantID: tenantId,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return cred, env, nil
+
+	case AuthMSI:
+		opt := &azidentity.ManagedIdentityCredentialOptions{ClientOptions: clientOpt}
+		if clientId != "" {
+			opt.ID = azidentity.ClientID(clientId)
+		}
+		cred, err := azidentity.NewManagedIdentityCredential(opt)
+		if err != nil {
+			return nil, nil, err
+		}
+		return cred, env, nil
+
+	case AuthSPSecret:
+		cred, err := azidentity.NewClientSecretCredential(tenantId, clientId, os.Getenv("ARM_CLIENT_SECRET"), &azidentity.ClientSecretCredentialOptions{
+			ClientOptions: clientOpt,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return cred, env, nil
+
+	case AuthSPCert:
+		path := os.Getenv("ARM_CLIENT_CERTIFICATE_PATH")
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading the client certificate %s: %v", path, err)
+		}
+		certs, key, err := azidentity.ParseCertificates(b, []byte(os.Getenv("ARM_CLIENT_CERTIFICATE_PASSWORD")))
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing the client certificate %s: %v", path, err)
+		}
+		cred, err := azidentity.NewClientCertificateCredential(tenantId, clientId, certs, key, &azidentity.ClientCertificateCredentialOptions{
+			ClientOptions: clientOpt,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		return cred, env, nil
+
+	case AuthOIDC:
+		return newOIDCCredential(cloudCfg, tenantId, clientId), env, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown authentication method: %q", auth)
+	}
+}
+
+// providerEnv returns the environment variables for the provider (see setProviderEnv), so that it authenticates via
+// the authentication method. The environment variables to unset have empty values.
+func providerEnv(auth string) (map[string]string, error) {
+	env := map[string]string{}
+	if auth != AuthDefault {
+		for _, k := range authEnvVars {
+			env[k] = ""
+		}
+	}
+
+	switch auth {
+	case AuthDefault:
+		return nil, nil
+
+	case AuthCLI:
+		return env, nil
+
+	case AuthMSI:
+		env["ARM_USE_MSI"] = "true"
+		return env, nil
+
+	case AuthSPSecret:
+		if err := requireEnvs(auth, "ARM_TENANT_ID", "ARM_CLIENT_ID", "ARM_CLIENT_SECRET"); err != nil {
+			return nil, err
+		}
+		env["ARM_CLIENT_SECRET"] = os.Getenv("ARM_CLIENT_SECRET")
+		return env, nil
+
+	case AuthSPCert:
+		if err := requireEnvs(auth, "ARM_TENANT_ID", "ARM_CLIENT_ID", "ARM_CLIENT_CERTIFICATE_PATH"); err != nil {
+			return nil, err
+		}
+		env["ARM_CLIENT_CERTIFICATE_PATH"] = os.Getenv("ARM_CLIENT_CERTIFICATE_PATH")
+		env["ARM_CLIENT_CERTIFICATE_PASSWORD"] = os.Getenv("ARM_CLIENT_CERTIFICATE_PASSWORD")
+		return env, nil
+
+	case AuthOIDC:
+		if err := requireEnvs(auth, "ARM_TENANT_ID", "ARM_CLIENT_ID"); err != nil {
+			return nil, err
+		}
+		if !hasOIDCToken() {
+			return nil, fmt.Errorf("authentication method %q requires one of ARM_OIDC_TOKEN, ARM_OIDC_TOKEN_FILE_PATH, AZURE_FEDERATED_TOKEN_FILE, and both ARM_OIDC_REQUEST_TOKEN and ARM_OIDC_REQUEST_URL", auth)
+		}
+		env["ARM_USE_OIDC"] = "true"
+		// The token sources are passed as is, rather than the token, so that the provider reads the token (which might
+		// be rotated) by itself.
+		for _, k := range []string{"ARM_OIDC_TOKEN", "ARM_OIDC_TOKEN_FILE_PATH", "ARM_OIDC_REQUEST_TOKEN", "ARM_OIDC_REQUEST_URL"} {
+			env[k] = os.Getenv(k)
+		}
+		if env["ARM_OIDC_TOKEN_FILE_PATH"] == "" {
+			env["ARM_OIDC_TOKEN_FILE_PATH"] = os.Getenv("AZURE_FEDERATED_TOKEN_FILE")
+		}
+		return env, nil
+
+	default:
+		return nil, fmt.Errorf("unknown authentication method: %q", auth)
+	}
+}
+
+// SetupProviderAuth sets the environment variables for the provider to authenticate via the authentication method,
+// without building the credential. It is used when aztfy itself doesn't talk to Azure (e.g. reading the resources
+// from an ARM template file), while the provider still does.
+func SetupProviderAuth(auth string) error {
+	if auth == "" {
+		auth = AuthDefault
+	}
+	env, err := providerEnv(auth)
+	if err != nil {
+		return err
+	}
+	return setProviderEnv(env)
+}
+
+// setProviderEnv sets the environment variables for the provider in the current process, which are then inherited by
+// the Terraform subprocess, together with the others (e.g. TF_VAR_*). The ones of empty values are unset.
+func setProviderEnv(env map[string]string) error {
+	for k, v := range env {
+		if v == "" {
+			if err := os.Unsetenv(k); err != nil {
+				return fmt.Errorf("unsetting %s: %v", k, err)
+			}
+			continue
+		}
+		if err := os.Setenv(k, v); err != nil {
+			return fmt.Errorf("setting %s: %v", k, err)
+		}
+	}
+	return nil
+}
+
+// hasOIDCToken tells whether any source of the OIDC token (see oidcToken) is specified.
+func hasOIDCToken() bool {
+	return os.Getenv("ARM_OIDC_TOKEN") != "" ||
+		os.Getenv("ARM_OIDC_TOKEN_FILE_PATH") != "" ||
+		os.Getenv("AZURE_FEDERATED_TOKEN_FILE") != "" ||
+		(os.Getenv("ARM_OIDC_REQUEST_TOKEN") != "" && os.Getenv("ARM_OIDC_REQUEST_URL") != "")
+}
+
+// oidcToken returns the OIDC token, which comes from one of following (starts from the highest priority):
+// - Env variable: ARM_OIDC_TOKEN
+// - Env variable: ARM_OIDC_TOKEN_FILE_PATH, the file containing the token
+// - Env variable: AZURE_FEDERATED_TOKEN_FILE, the file containing the token, as is set by the workload identity
+// - Env variable: ARM_OIDC_REQUEST_TOKEN and ARM_OIDC_REQUEST_URL, to request the token, as is set by GitHub Actions
+func oidcToken(ctx context.Context) (string, error) {
+	if v := os.Getenv("ARM_OIDC_TOKEN"); v != "" {
+		return v, nil
+	}
+	for _, k := range []string{"ARM_OIDC_TOKEN_FILE_PATH", "AZURE_FEDERATED_TOKEN_FILE"} {
+		path := os.Getenv(k)
+		if path == "" {
+			continue
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("reading the OIDC token file %s: %v", path, err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	reqToken, reqURL := os.Getenv("ARM_OIDC_REQUEST_TOKEN"), os.Getenv("ARM_OIDC_REQUEST_URL")
+	if reqToken != "" && reqURL != "" {
+		return requestOIDCToken(ctx, &http.Client{Timeout: time.Minute}, reqURL, reqToken)
+	}
+	return "", fmt.Errorf("no OIDC token specified")
+}
+
+// requestOIDCToken requests the OIDC token of the audience of Azure AD, e.g. from the GitHub Actions token endpoint.
+func requestOIDCToken(ctx context.Context, client *http.Client, reqURL, reqToken string) (string, error) {
+	u, err := url.Parse(reqURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing the OIDC request url: %v", err)
+	}
+	query := u.Query()
+	query.Set("audience", "api://AzureADTokenExchange")
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("building the OIDC token request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+reqToken)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting the OIDC token: %v", err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("reading the OIDC token response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("requesting the OIDC token: unexpected status %d: %s", resp.StatusCode, string(b))
+	}
+	var result struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return "", fmt.Errorf("unmarshalling the OIDC token response: %v", err)
+	}
+	if result.Value == "" {
+		return "", fmt.Errorf("no OIDC token in the response")
+	}
+	return result.Value, nil
+}
+
+// requireEnvs returns an error if any of the environment variables is not set.
+func requireEnvs(auth string, names ...string) error {
+	var missing []string
+	for _, k := range names {
+		if os.Getenv(k) == "" {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) != 0 {
+		return fmt.Errorf("authentication method %q requires %s", auth, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// oidcCredential authenticates via the service principal with an OIDC token, as the client assertion. The token is
+// read on each authentication, as it might be rotated (e.g. the workload identity) or short lived (e.g. GitHub Actions).
+type oidcCredential struct {
+	authority string
+	clientId  string
+}
+
+var _ azcore.TokenCredential = oidcCredential{}
+
+func newOIDCCredential(cloudCfg cloud.Configuration, tenantId, clientId string) *oidcCredential {
+	return &oidcCredential{
+		authority: strings.TrimSuffix(cloudCfg.ActiveDirectoryAuthorityHost, "/") + "/" + tenantId,
+		clientId:  clientId,
+	}
+}
+
+func (c oidcCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	token, err := oidcToken(ctx)
+	if err != nil {
+		return azcore.AccessToken{}, err
+	}
+	cred, err := confidential.NewCredFromAssertion(token)
+	if err != nil {
+		return azcore.AccessToken{}, err
+	}
+	client, err := confidential.New(c.clientId, cred, confidential.WithAuthority(c.authority))
+	if err != nil {
+		return azcore.AccessToken{}, err
+	}
+	result, err := client.AcquireTokenByCredential(ctx, opts.Scopes)
+	if err != nil {
+		return azcore.AccessToken{}, err
+	}
+	return azcore.AccessToken{Token: result.AccessToken, ExpiresOn: result.ExpiresOn}, nil
+}
@@ -0,0 +1,233 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCertificate writes a self-signed certificate, together with its private key, in PEM to a temp file.
+func writeTestCertificate(t *testing.T) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "aztfy-test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	b := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	b = append(b, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})...)
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	require.NoError(t, os.WriteFile(path, b, 0600))
+	return path
+}
+
+func TestNewCredential(t *testing.T) {
+	certPath := writeTestCertificate(t)
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenPath, []byte("oidc-token\n"), 0600))
+
+	cleared := func(env map[string]string) map[string]string {
+		out := map[string]string{}
+		for _, k := range authEnvVars {
+			out[k] = ""
+		}
+		for k, v := range env {
+			out[k] = v
+		}
+		return out
+	}
+
+	cases := []struct {
+		name       string
+		auth       string
+		envs       map[string]string
+		credential azcore.TokenCredential
+		tfEnv      map[string]string
+		err        string
+	}{
+		{
+			name:       "default",
+			auth:       AuthDefault,
+			credential: &azidentity.DefaultAzureCredential{},
+		},
+		{
+			name:       "cli",
+			auth:       AuthCLI,
+			envs:       map[string]string{"ARM_CLIENT_SECRET": "secret", "ARM_USE_MSI": "true"},
+			credential: &azidentity.AzureCLICredential{},
+			tfEnv:      cleared(nil),
+		},
+		{
+			name:       "msi",
+			auth:       AuthMSI,
+			envs:       map[string]string{"ARM_CLIENT_ID": "client"},
+			credential: &azidentity.ManagedIdentityCredential{},
+			tfEnv:      cleared(map[string]string{"ARM_USE_MSI": "true"}),
+		},
+		{
+			name:       "sp-secret",
+			auth:       AuthSPSecret,
+			envs:       map[string]string{"ARM_TENANT_ID": "tenant", "ARM_CLIENT_ID": "client", "ARM_CLIENT_SECRET": "secret", "ARM_USE_OIDC": "true"},
+			credential: &azidentity.ClientSecretCredential{},
+			tfEnv:      cleared(map[string]string{"ARM_CLIENT_SECRET": "secret"}),
+		},
+		{
+			name: "sp-secret without secret",
+			auth: AuthSPSecret,
+			envs: map[string]string{"ARM_TENANT_ID": "tenant", "ARM_CLIENT_ID": "client"},
+			err:  `authentication method "sp-secret" requires ARM_CLIENT_SECRET`,
+		},
+		{
+			name:       "sp-cert",
+			auth:       AuthSPCert,
+			envs:       map[string]string{"ARM_TENANT_ID": "tenant", "ARM_CLIENT_ID": "client", "ARM_CLIENT_CERTIFICATE_PATH": certPath},
+			credential: &azidentity.ClientCertificateCredential{},
+			tfEnv:      cleared(map[string]string{"ARM_CLIENT_CERTIFICATE_PATH": certPath}),
+		},
+		{
+			name: "sp-cert without tenant and client",
+			auth: AuthSPCert,
+			envs: map[string]string{"ARM_CLIENT_CERTIFICATE_PATH": certPath},
+			err:  `authentication method "sp-cert" requires ARM_TENANT_ID, ARM_CLIENT_ID`,
+		},
+		{
+			name:       "oidc with token file",
+			auth:       AuthOIDC,
+			envs:       map[string]string{"ARM_TENANT_ID": "tenant", "ARM_CLIENT_ID": "client", "AZURE_FEDERATED_TOKEN_FILE": tokenPath},
+			credential: &oidcCredential{},
+			tfEnv:      cleared(map[string]string{"ARM_USE_OIDC": "true", "ARM_OIDC_TOKEN_FILE_PATH": tokenPath}),
+		},
+		{
+			name:       "oidc with request token",
+			auth:       AuthOIDC,
+			envs:       map[string]string{"ARM_TENANT_ID": "tenant", "ARM_CLIENT_ID": "client", "ARM_OIDC_REQUEST_TOKEN": "request-token", "ARM_OIDC_REQUEST_URL": "https://token.actions.githubusercontent.com/?foo=bar"},
+			credential: &oidcCredential{},
+			tfEnv:      cleared(map[string]string{"ARM_USE_OIDC": "true", "ARM_OIDC_REQUEST_TOKEN": "request-token", "ARM_OIDC_REQUEST_URL": "https://token.actions.githubusercontent.com/?foo=bar"}),
+		},
+		{
+			name: "oidc without token",
+			auth: AuthOIDC,
+			envs: map[string]string{"ARM_TENANT_ID": "tenant", "ARM_CLIENT_ID": "client", "ARM_OIDC_REQUEST_TOKEN": "request-token"},
+			err:  `authentication method "oidc" requires one of ARM_OIDC_TOKEN, ARM_OIDC_TOKEN_FILE_PATH, AZURE_FEDERATED_TOKEN_FILE, and both ARM_OIDC_REQUEST_TOKEN and ARM_OIDC_REQUEST_URL`,
+		},
+		{
+			name: "unknown",
+			auth: "foo",
+			err:  `unknown authentication method: "foo"`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for _, k := range append([]string{"ARM_TENANT_ID", "ARM_CLIENT_ID", "AZURE_FEDERATED_TOKEN_FILE"}, authEnvVars...) {
+				t.Setenv(k, c.envs[k])
+			}
+			cred, tfEnv, err := newCredential(c.auth, cloud.AzurePublic)
+			if c.err != "" {
+				require.EqualError(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			require.IsType(t, c.credential, cred)
+			require.Equal(t, c.tfEnv, tfEnv)
+		})
+	}
+}
+
+func TestSetProviderEnv(t *testing.T) {
+	t.Setenv("ARM_CLIENT_SECRET", "secret")
+	t.Setenv("ARM_USE_MSI", "")
+	t.Setenv("TF_VAR_foo", "foo")
+	require.NoError(t, setProviderEnv(map[string]string{"ARM_CLIENT_SECRET": "", "ARM_USE_MSI": "true"}))
+	_, ok := os.LookupEnv("ARM_CLIENT_SECRET")
+	require.False(t, ok)
+	require.Equal(t, "true", os.Getenv("ARM_USE_MSI"))
+	// The other environment variables are kept.
+	require.Equal(t, "foo", os.Getenv("TF_VAR_foo"))
+}
+
+func TestOIDCToken(t *testing.T) {
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenPath, []byte("file-token\n"), 0600))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer request-token" ||
+			r.URL.Query().Get("audience") != "api://AzureADTokenExchange" ||
+			r.URL.Query().Get("foo") != "bar" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"count":1,"value":"requested-token"}`))
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		name   string
+		envs   map[string]string
+		expect string
+		err    bool
+	}{
+		{
+			name:   "token",
+			envs:   map[string]string{"ARM_OIDC_TOKEN": "token", "ARM_OIDC_TOKEN_FILE_PATH": tokenPath},
+			expect: "token",
+		},
+		{
+			name:   "token file",
+			envs:   map[string]string{"ARM_OIDC_TOKEN_FILE_PATH": tokenPath, "ARM_OIDC_REQUEST_TOKEN": "request-token", "ARM_OIDC_REQUEST_URL": server.URL + "/?foo=bar"},
+			expect: "file-token",
+		},
+		{
+			name:   "federated token file",
+			envs:   map[string]string{"AZURE_FEDERATED_TOKEN_FILE": tokenPath},
+			expect: "file-token",
+		},
+		{
+			name:   "request token",
+			envs:   map[string]string{"ARM_OIDC_REQUEST_TOKEN": "request-token", "ARM_OIDC_REQUEST_URL": server.URL + "/?foo=bar"},
+			expect: "requested-token",
+		},
+		{
+			name: "invalid request token",
+			envs: map[string]string{"ARM_OIDC_REQUEST_TOKEN": "foo", "ARM_OIDC_REQUEST_URL": server.URL + "/?foo=bar"},
+			err:  true,
+		},
+		{
+			name: "no token",
+			err:  true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for _, k := range []string{"ARM_OIDC_TOKEN", "ARM_OIDC_TOKEN_FILE_PATH", "AZURE_FEDERATED_TOKEN_FILE", "ARM_OIDC_REQUEST_TOKEN", "ARM_OIDC_REQUEST_URL"} {
+				t.Setenv(k, c.envs[k])
+			}
+			token, err := oidcToken(context.Background())
+			if c.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expect, token)
+		})
+	}
+}
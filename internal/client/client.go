@@ -3,66 +3,60 @@ package client
 import (
 	"fmt"
 	"os"
-	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
-	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/keyvault/armkeyvault"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 )
 
 type ClientBuilder struct {
-	credential azcore.TokenCredential
-	opt        *arm.ClientOptions
+	credential  azcore.TokenCredential
+	opt         *arm.ClientOptions
+	environment Environment
 }
 
-func NewClientBuilder() (*ClientBuilder, error) {
-	env := "public"
-	if v := os.Getenv("ARM_ENVIRONMENT"); v != "" {
-		env = v
+// NewClientBuilder builds the clients that authenticate via the authentication method (one of PossibleAuthValues()),
+// empty means AuthDefault.
+func NewClientBuilder(auth string) (*ClientBuilder, error) {
+	if auth == "" {
+		auth = AuthDefault
 	}
 
-	var cloudCfg cloud.Configuration
-	switch strings.ToLower(env) {
-	case "public":
-		cloudCfg = cloud.AzurePublic
-	case "usgovernment":
-		cloudCfg = cloud.AzureGovernment
-	case "china":
-		cloudCfg = cloud.AzureChina
-	default:
-		return nil, fmt.Errorf("unknown environment specified: %q", env)
+	env, err := EnvironmentFromEnv()
+	if err != nil {
+		return nil, err
 	}
+	cloudCfg := env.Cloud
 
-	// Maps the auth related environment variables used in the provider to what azidentity honors.
-	if v, ok := os.LookupEnv("ARM_TENANT_ID"); ok {
-		os.Setenv("AZURE_TENANT_ID", v)
-	}
-	if v, ok := os.LookupEnv("ARM_CLIENT_ID"); ok {
-		os.Setenv("AZURE_CLIENT_ID", v)
-	}
-	if v, ok := os.LookupEnv("ARM_CLIENT_SECRET"); ok {
-		os.Setenv("AZURE_CLIENT_SECRET", v)
-	}
-	if v, ok := os.LookupEnv("ARM_CLIENT_CERTIFICATE_PATH"); ok {
-		os.Setenv("AZURE_CLIENT_CERTIFICATE_PATH", v)
+	// The recorder (if enabled) records, or replays, the interactions with Azure. The authentication is never recorded.
+	var recorder *Recorder
+	if mode := os.Getenv(RecorderModeEnvVar); mode != "" {
+		var err error
+		recorder, err = NewRecorder(mode, os.Getenv(RecorderCassetteEnvVar))
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	cred, err := azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
-		ClientOptions: policy.ClientOptions{
-			Cloud: cloudCfg,
-		},
-		TenantID: os.Getenv("ARM_TENANT_ID"),
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to obtain a credential: %v", err)
+	var cred azcore.TokenCredential
+	if recorder != nil && recorder.mode == RecorderModeReplay {
+		cred = replayCredential{}
+	} else {
+		var providerEnv map[string]string
+		cred, providerEnv, err = newCredential(auth, cloudCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to obtain a credential: %v", err)
+		}
+		if err := setProviderEnv(providerEnv); err != nil {
+			return nil, err
+		}
 	}
 
-	return &ClientBuilder{
-		credential: cred,
+	b := &ClientBuilder{
+		environment: *env,
+		credential:  cred,
 		opt: &arm.ClientOptions{
 			ClientOptions: policy.ClientOptions{
 				Cloud: cloudCfg,
@@ -75,7 +69,16 @@ func NewClientBuilder() (*ClientBuilder, error) {
 				},
 			},
 		},
-	}, nil
+	}
+	if recorder != nil {
+		b.opt.Transport = recorder
+	}
+	return b, nil
+}
+
+// Environment returns the cloud environment that the clients talk to.
+func (b *ClientBuilder) Environment() Environment {
+	return b.environment
 }
 
 func (b *ClientBuilder) NewResourceGroupClient(subscriptionId string) (*armresources.ResourceGroupsClient, error) {
@@ -86,6 +89,22 @@ func (b *ClientBuilder) NewResourceGroupClient(subscriptionId string) (*armresou
 	)
 }
 
+func (b *ClientBuilder) NewResourcesClient(subscriptionId string) (*armresources.Client, error) {
+	return armresources.NewClient(
+		subscriptionId,
+		b.credential,
+		b.opt,
+	)
+}
+
+func (b *ClientBuilder) NewProvidersClient(subscriptionId string) (*armresources.ProvidersClient, error) {
+	return armresources.NewProvidersClient(
+		subscriptionId,
+		b.credential,
+		b.opt,
+	)
+}
+
 func (b *ClientBuilder) NewKeyvaultKeysClient(subscriptionId string) (*armkeyvault.KeysClient, error) {
 	return armkeyvault.NewKeysClient(
 		subscriptionId,
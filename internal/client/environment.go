@@ -0,0 +1,223 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+)
+
+const (
+	// EnvironmentEnvVar is the name of the builtin cloud environment, which is the same as the one used by the provider.
+	EnvironmentEnvVar = "ARM_ENVIRONMENT"
+
+	// MetadataHostEnvVar is the host serving the ARM metadata endpoint of a custom cloud, which is the same as the one
+	// used by the provider.
+	MetadataHostEnvVar = "ARM_METADATA_HOSTNAME"
+
+	// EnvironmentFileEnvVar is the local file of the ARM metadata of a custom cloud, in the same form as the response
+	// of the ARM metadata endpoint.
+	EnvironmentFileEnvVar = "AZTFY_ENVIRONMENT_FILE"
+)
+
+// metadataAPIVersion is the api version of the ARM metadata endpoint, which returns the metadata of the cloud that
+// the endpoint belongs to.
+const metadataAPIVersion = "2022-09-01"
+
+// Environment is the cloud environment that both aztfy and the AzureRM provider talk to.
+type Environment struct {
+	// The name of the environment, which is set to the "environment" of the provider. For the builtin clouds, this is
+	// one of "public", "usgovernment" and "china". For the custom clouds, this is the name in the metadata.
+	Name string
+
+	// The host serving the ARM metadata endpoint, which is set to the "metadata_host" of the provider. This is only
+	// set for the custom clouds.
+	MetadataHost string
+
+	Cloud cloud.Configuration
+}
+
+// metadata is the part of the response of the ARM metadata endpoint that is used by aztfy.
+type metadata struct {
+	Name            string `json:"name"`
+	ResourceManager string `json:"resourceManager"`
+	Authentication  struct {
+		LoginEndpoint string   `json:"loginEndpoint"`
+		Audiences     []string `json:"audiences"`
+	} `json:"authentication"`
+}
+
+// EnvironmentFromEnv returns the environment specified by the environment variables, which is one of following
+// (starts from the highest priority):
+// - Env variable: ARM_METADATA_HOSTNAME, the metadata is read from the endpoint
+// - Env variable: AZTFY_ENVIRONMENT_FILE, the metadata is read from the file
+// - Env variable: ARM_ENVIRONMENT, one of the builtin clouds (defaults to "public")
+func EnvironmentFromEnv() (*Environment, error) {
+	host := os.Getenv(MetadataHostEnvVar)
+	file := os.Getenv(EnvironmentFileEnvVar)
+	if host != "" && file != "" {
+		return nil, fmt.Errorf("%s conflicts with %s", MetadataHostEnvVar, EnvironmentFileEnvVar)
+	}
+	switch {
+	case host != "":
+		return EnvironmentFromMetadataHost(host)
+	case file != "":
+		return EnvironmentFromFile(file)
+	}
+
+	name := "public"
+	if v := os.Getenv(EnvironmentEnvVar); v != "" {
+		name = v
+	}
+	return EnvironmentFromName(name)
+}
+
+// EnvironmentFromName returns one of the builtin environments.
+func EnvironmentFromName(name string) (*Environment, error) {
+	var cloudCfg cloud.Configuration
+	switch strings.ToLower(name) {
+	case "public":
+		cloudCfg = cloud.AzurePublic
+	case "usgovernment":
+		cloudCfg = cloud.AzureGovernment
+	case "china":
+		cloudCfg = cloud.AzureChina
+	default:
+		return nil, fmt.Errorf("unknown environment specified: %q", name)
+	}
+	return &Environment{Name: strings.ToLower(name), Cloud: cloudCfg}, nil
+}
+
+// EnvironmentFromMetadataHost returns the environment of a custom cloud, by reading the ARM metadata endpoint served
+// by the host (e.g. "management.azure.com").
+func EnvironmentFromMetadataHost(host string) (*Environment, error) {
+	client := &http.Client{Timeout: time.Minute}
+	return environmentFromMetadataHost(context.TODO(), client, host)
+}
+
+func environmentFromMetadataHost(ctx context.Context, client *http.Client, host string) (*Environment, error) {
+	u := fmt.Sprintf("https://%s/metadata/endpoints?api-version=%s", host, metadataAPIVersion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building the request to %s: %v", u, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("reading the metadata from %s: %v", u, err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading the metadata from %s: %v", u, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("reading the metadata from %s: unexpected status %d: %s", u, resp.StatusCode, string(b))
+	}
+	env, err := environmentFromMetadata(b)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the metadata from %s: %v", u, err)
+	}
+	env.MetadataHost = host
+	return env, nil
+}
+
+// EnvironmentFromFile returns the environment of a custom cloud, by reading the metadata from the file. The metadata
+// is expected to be served by the resource manager endpoint, whose host is used as the metadata host of the provider.
+func EnvironmentFromFile(path string) (*Environment, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading the environment file %s: %v", path, err)
+	}
+	env, err := environmentFromMetadata(b)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the environment file %s: %v", path, err)
+	}
+	u, err := url.Parse(env.Cloud.Services[cloud.ResourceManager].Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the resource manager endpoint of the environment file %s: %v", path, err)
+	}
+	env.MetadataHost = u.Host
+	return env, nil
+}
+
+// environmentFromMetadata parses the metadata, which is an array of clouds as returned by the api version 2022-09-01,
+// or a single cloud as returned by the older api versions (e.g. of Azure Stack). Among the array, the only cloud is
+// picked, or otherwise the one named by ARM_ENVIRONMENT (either the metadata name, e.g. "AzureCloud", or the builtin
+// name, e.g. "public", which is the default).
+func environmentFromMetadata(b []byte) (*Environment, error) {
+	var md metadata
+	if trimmed := bytes.TrimSpace(b); len(trimmed) != 0 && trimmed[0] == '[' {
+		var mds []metadata
+		if err := json.Unmarshal(b, &mds); err != nil {
+			return nil, err
+		}
+		v, err := pickMetadata(mds, os.Getenv(EnvironmentEnvVar))
+		if err != nil {
+			return nil, err
+		}
+		md = *v
+	} else if err := json.Unmarshal(b, &md); err != nil {
+		return nil, err
+	}
+	if md.Name == "" {
+		return nil, fmt.Errorf("no name")
+	}
+	if md.ResourceManager == "" {
+		return nil, fmt.Errorf("no resource manager endpoint")
+	}
+	if md.Authentication.LoginEndpoint == "" {
+		return nil, fmt.Errorf("no login endpoint")
+	}
+	if len(md.Authentication.Audiences) == 0 {
+		return nil, fmt.Errorf("no audience")
+	}
+	return &Environment{
+		Name: md.Name,
+		Cloud: cloud.Configuration{
+			ActiveDirectoryAuthorityHost: md.Authentication.LoginEndpoint,
+			Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+				cloud.ResourceManager: {
+					Endpoint: md.ResourceManager,
+					Audience: md.Authentication.Audiences[0],
+				},
+			},
+		},
+	}, nil
+}
+
+// metadataNames maps the builtin names of the environments to their names in the metadata.
+var metadataNames = map[string]string{
+	"public":       "AzureCloud",
+	"usgovernment": "AzureUSGovernment",
+	"china":        "AzureChinaCloud",
+}
+
+func pickMetadata(mds []metadata, name string) (*metadata, error) {
+	switch {
+	case len(mds) == 0:
+		return nil, fmt.Errorf("no cloud")
+	case len(mds) == 1 && name == "":
+		return &mds[0], nil
+	case name == "":
+		name = "public"
+	}
+	if v, ok := metadataNames[strings.ToLower(name)]; ok {
+		name = v
+	}
+	var names []string
+	for i, md := range mds {
+		if strings.EqualFold(md.Name, name) {
+			return &mds[i], nil
+		}
+		names = append(names, md.Name)
+	}
+	return nil, fmt.Errorf("no cloud named %q (specified by %s), expect one of %s", name, EnvironmentEnvVar, strings.Join(names, ", "))
+}
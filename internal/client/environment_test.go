@@ -0,0 +1,207 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/stretchr/testify/require"
+)
+
+var azureStackCloud = cloud.Configuration{
+	ActiveDirectoryAuthorityHost: "https://login.microsoftonline.com/",
+	Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+		cloud.ResourceManager: {
+			Endpoint: "https://management.local.azurestack.external/",
+			Audience: "https://management.adfs.azurestack.local/00000000-0000-0000-0000-000000000000",
+		},
+	},
+}
+
+func TestEnvironmentFromEnv(t *testing.T) {
+	envFile := filepath.Join("testdata", "environment_azurestack.json")
+	cases := []struct {
+		name   string
+		envs   map[string]string
+		expect *Environment
+		err    bool
+	}{
+		{
+			name:   "default",
+			expect: &Environment{Name: "public", Cloud: cloud.AzurePublic},
+		},
+		{
+			name:   "builtin cloud",
+			envs:   map[string]string{EnvironmentEnvVar: "USGovernment"},
+			expect: &Environment{Name: "usgovernment", Cloud: cloud.AzureGovernment},
+		},
+		{
+			name: "unknown builtin cloud",
+			envs: map[string]string{EnvironmentEnvVar: "german"},
+			err:  true,
+		},
+		{
+			name: "environment file",
+			envs: map[string]string{EnvironmentFileEnvVar: envFile},
+			expect: &Environment{
+				Name:         "AzureStackCloud",
+				MetadataHost: "management.local.azurestack.external",
+				Cloud:        azureStackCloud,
+			},
+		},
+		{
+			name: "environment file not exist",
+			envs: map[string]string{EnvironmentFileEnvVar: filepath.Join("testdata", "not_exist.json")},
+			err:  true,
+		},
+		{
+			name: "metadata host conflicts with environment file",
+			envs: map[string]string{EnvironmentFileEnvVar: envFile, MetadataHostEnvVar: "management.local.azurestack.external"},
+			err:  true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for _, k := range []string{EnvironmentEnvVar, MetadataHostEnvVar, EnvironmentFileEnvVar} {
+				t.Setenv(k, c.envs[k])
+			}
+			env, err := EnvironmentFromEnv()
+			if c.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expect, env)
+		})
+	}
+}
+
+func TestEnvironmentFromMetadataHost(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "environment_azurestack.json"))
+	require.NoError(t, err)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metadata/endpoints" || r.URL.Query().Get("api-version") != metadataAPIVersion {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	env, err := environmentFromMetadataHost(context.Background(), server.Client(), u.Host)
+	require.NoError(t, err)
+	require.Equal(t, &Environment{
+		Name:         "AzureStackCloud",
+		MetadataHost: u.Host,
+		Cloud:        azureStackCloud,
+	}, env)
+}
+
+func TestEnvironmentFromMetadata(t *testing.T) {
+	builtin, err := os.ReadFile(filepath.Join("testdata", "environment_builtin.json"))
+	require.NoError(t, err)
+	stack, err := os.ReadFile(filepath.Join("testdata", "environment_azurestack.json"))
+	require.NoError(t, err)
+	cases := []struct {
+		name        string
+		input       []byte
+		environment string
+		expect      string
+		err         bool
+	}{
+		{
+			name:   "the only cloud",
+			input:  stack,
+			expect: "AzureStackCloud",
+		},
+		{
+			name:        "the only cloud by name",
+			input:       stack,
+			environment: "azurestackcloud",
+			expect:      "AzureStackCloud",
+		},
+		{
+			name:        "the only cloud of another name",
+			input:       stack,
+			environment: "AzureCloud",
+			err:         true,
+		},
+		{
+			name:   "public cloud by default",
+			input:  builtin,
+			expect: "AzureCloud",
+		},
+		{
+			name:        "cloud by builtin name",
+			input:       builtin,
+			environment: "china",
+			expect:      "AzureChinaCloud",
+		},
+		{
+			name:        "cloud by metadata name",
+			input:       builtin,
+			environment: "AzureUSGovernment",
+			expect:      "AzureUSGovernment",
+		},
+		{
+			name:        "unknown cloud",
+			input:       builtin,
+			environment: "german",
+			err:         true,
+		},
+		{
+			name:   "single cloud of the older api versions",
+			input:  []byte(`{"name": "foo", "resourceManager": "https://management.foo/", "authentication": {"loginEndpoint": "https://login.foo/", "audiences": ["https://management.foo/"]}}`),
+			expect: "foo",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Setenv(EnvironmentEnvVar, c.environment)
+			env, err := environmentFromMetadata(c.input)
+			if c.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expect, env.Name)
+		})
+	}
+}
+
+func TestEnvironmentFromMetadata_invalid(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "invalid json",
+			input: `{`,
+		},
+		{
+			name:  "no cloud",
+			input: `[]`,
+		},
+		{
+			name:  "no resource manager endpoint",
+			input: `{"name": "foo", "authentication": {"loginEndpoint": "https://login.foo/", "audiences": ["https://management.foo/"]}}`,
+		},
+		{
+			name:  "no audience",
+			input: `{"name": "foo", "resourceManager": "https://management.foo/", "authentication": {"loginEndpoint": "https://login.foo/"}}`,
+		},
+	}
+	for _, c := range cases {
+		_, err := environmentFromMetadata([]byte(c.input))
+		require.Error(t, err, c.name)
+	}
+}
@@ -0,0 +1,355 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+)
+
+// The environment variables to enable the recorder for the clients built by the ClientBuilder.
+const (
+	// RecorderModeEnvVar specifies the recorder mode, which is one of RecorderModeRecord and RecorderModeReplay.
+	RecorderModeEnvVar = "AZTFY_RECORDER_MODE"
+	// RecorderCassetteEnvVar specifies the path of the cassette file.
+	RecorderCassetteEnvVar = "AZTFY_RECORDER_CASSETTE"
+)
+
+const (
+	// RecorderModeRecord sends the requests to Azure, and records each request/response into the cassette.
+	RecorderModeRecord = "record"
+	// RecorderModeReplay serves the requests from the cassette, without talking to Azure.
+	RecorderModeReplay = "replay"
+)
+
+const redacted = "REDACTED"
+
+// RecordedSubscriptionId is the subscription id that the real ones are rewritten to in the recorded interactions, so
+// that the cassettes don't disclose the real subscription ids, and can be replayed with this fixed one.
+const RecordedSubscriptionId = "00000000-0000-0000-0000-000000000000"
+
+var (
+	// The headers that are scrubbed from the recorded requests and responses.
+	secretHeaderPattern = regexp.MustCompile(`(?i)^(authorization|cookie|set-cookie)$|token|secret|key`)
+	// The JSON fields (of string value) that are scrubbed from the recorded request and response bodies.
+	secretFieldPattern = regexp.MustCompile(`(?i)token|secret|password|connectionstring|primarykey|secondarykey|sas`)
+	// The URL query parameters that are scrubbed from the recorded request URLs (e.g. the signature of a SAS). Note that
+	// the paging ones (e.g. "$skiptoken") are not secrets, which are kept for replaying.
+	secretQueryPattern = regexp.MustCompile(`(?i)^(sig|code|token|access_token)$|secret|password`)
+	// The subscription id in the URL path.
+	subscriptionIdPattern = regexp.MustCompile(`(?i)/subscriptions/([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})`)
+)
+
+// Cassette records the HTTP interactions, in the order they happened.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Recorder is a policy.Transporter, which either records the HTTP interactions into a cassette file, or replays them
+// from the cassette file.
+type Recorder struct {
+	mode string
+	path string
+
+	// The transporter used to send the requests in record mode.
+	transport policy.Transporter
+
+	mu       sync.Mutex
+	cassette Cassette
+	// The (lower cased) real subscription ids seen in the request URLs in record mode, which are rewritten to
+	// RecordedSubscriptionId wherever they appear.
+	subscriptionIds map[string]bool
+	// Whether each interaction has been replayed, in replay mode.
+	replayed []bool
+}
+
+var _ policy.Transporter = &Recorder{}
+
+// NewRecorder creates a recorder of the mode. In record mode, the cassette file is (re)created as the requests are
+// sent. In replay mode, the cassette file is loaded.
+func NewRecorder(mode, path string) (*Recorder, error) {
+	r := &Recorder{
+		mode:            mode,
+		path:            path,
+		subscriptionIds: map[string]bool{},
+	}
+	switch mode {
+	case RecorderModeRecord:
+		r.transport = http.DefaultClient
+		if err := r.save(); err != nil {
+			return nil, err
+		}
+	case RecorderModeReplay:
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading the cassette file %s: %v", path, err)
+		}
+		if err := json.Unmarshal(b, &r.cassette); err != nil {
+			return nil, fmt.Errorf("unmarshalling the cassette file %s: %v", path, err)
+		}
+		r.replayed = make([]bool, len(r.cassette.Interactions))
+	default:
+		return nil, fmt.Errorf("unknown recorder mode %q, expect one of %q or %q", mode, RecorderModeRecord, RecorderModeReplay)
+	}
+	return r, nil
+}
+
+// Do implements the policy.Transporter.
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	if r.mode == RecorderModeReplay {
+		return r.replay(req)
+	}
+	return r.record(req)
+}
+
+func (r *Recorder) record(req *http.Request) (*http.Response, error) {
+	reqBody, err := drainBody(&req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading the request body: %v", err)
+	}
+	resp, err := r.transport.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := drainBody(&resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading the response body: %v", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, match := range subscriptionIdPattern.FindAllStringSubmatch(req.URL.Path, -1) {
+		if id := strings.ToLower(match[1]); id != RecordedSubscriptionId {
+			r.subscriptionIds[id] = true
+		}
+	}
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    recordedURL(req.URL),
+			Header: r.rewriteHeader(scrubHeader(req.Header)),
+			Body:   r.rewriteSubscriptionIds(scrubBody(reqBody)),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     r.rewriteHeader(scrubHeader(resp.Header)),
+			Body:       r.rewriteSubscriptionIds(scrubBody(respBody)),
+		},
+	})
+	// The cassette is saved on each interaction, so that there is no need to explicitly stop the recorder.
+	if err := r.save(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replay serves the request with the first interaction that has the same method and URL (as is recorded), and is not
+// replayed yet. This allows the same request (e.g. the polling of a long running operation) to get different responses
+// in order.
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reqURL := recordedURL(req.URL)
+	for i, interaction := range r.cassette.Interactions {
+		if r.replayed[i] || interaction.Request.Method != req.Method || interaction.Request.URL != reqURL {
+			continue
+		}
+		r.replayed[i] = true
+		header := interaction.Response.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(strings.NewReader(interaction.Response.Body)),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, replayMissError{fmt.Errorf("no interaction recorded in %s for %s %s", r.path, req.Method, req.URL)}
+}
+
+// replayMissError is returned when there is no interaction to replay, which is not retriable by the retry policy.
+type replayMissError struct {
+	error
+}
+
+func (replayMissError) NonRetriable() {}
+
+func (r *Recorder) save() error {
+	b, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling the cassette: %v", err)
+	}
+	if err := os.WriteFile(r.path, b, 0644); err != nil {
+		return fmt.Errorf("writing the cassette file %s: %v", r.path, err)
+	}
+	return nil
+}
+
+// drainBody reads the body, and resets it with the read content so that it can be read again.
+func drainBody(body *io.ReadCloser) (string, error) {
+	if *body == nil || *body == http.NoBody {
+		return "", nil
+	}
+	b, err := io.ReadAll(*body)
+	if err != nil {
+		return "", err
+	}
+	if err := (*body).Close(); err != nil {
+		return "", err
+	}
+	*body = io.NopCloser(bytes.NewReader(b))
+	return string(b), nil
+}
+
+// recordedURL returns the URL as is recorded, where the subscription id is rewritten to RecordedSubscriptionId, and the
+// secret query parameters are scrubbed. The query is kept as is if there is nothing to scrub.
+func recordedURL(u *url.URL) string {
+	out := *u
+	out.Path = subscriptionIdPattern.ReplaceAllString(out.Path, "/subscriptions/"+RecordedSubscriptionId)
+	out.RawPath = subscriptionIdPattern.ReplaceAllString(out.RawPath, "/subscriptions/"+RecordedSubscriptionId)
+	scrubQuery(&out)
+	return out.String()
+}
+
+// scrubQuery scrubs the secret query parameters of the URL, in place. The query is kept as is if there is nothing to
+// scrub.
+func scrubQuery(u *url.URL) {
+	query := u.Query()
+	var scrubbed bool
+	for k := range query {
+		if secretQueryPattern.MatchString(k) {
+			query[k] = []string{redacted}
+			scrubbed = true
+		}
+	}
+	if scrubbed {
+		u.RawQuery = query.Encode()
+	}
+}
+
+// rewriteSubscriptionIds rewrites the real subscription ids seen so far to RecordedSubscriptionId, case insensitively.
+func (r *Recorder) rewriteSubscriptionIds(s string) string {
+	for id := range r.subscriptionIds {
+		s = regexp.MustCompile(`(?i)`+regexp.QuoteMeta(id)).ReplaceAllString(s, RecordedSubscriptionId)
+	}
+	return s
+}
+
+// rewriteHeader rewrites the real subscription ids in the header values (e.g. the "Location" of a long running
+// operation), in place.
+func (r *Recorder) rewriteHeader(header http.Header) http.Header {
+	for k, v := range header {
+		for i := range v {
+			v[i] = r.rewriteSubscriptionIds(v[i])
+		}
+		header[k] = v
+	}
+	return header
+}
+
+func scrubHeader(header http.Header) http.Header {
+	out := http.Header{}
+	for k, v := range header {
+		if secretHeaderPattern.MatchString(k) {
+			out[k] = []string{redacted}
+			continue
+		}
+		out[k] = append([]string{}, v...)
+	}
+	return out
+}
+
+// scrubBody scrubs the secret fields in the JSON body. The non-JSON body is kept as is.
+func scrubBody(body string) string {
+	if body == "" {
+		return ""
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(body), &v); err != nil {
+		return body
+	}
+	b, err := json.Marshal(scrubValue(v))
+	if err != nil {
+		return body
+	}
+	return string(b)
+}
+
+func scrubValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if s, ok := e.(string); ok && secretFieldPattern.MatchString(k) {
+				v[k] = scrubSecretField(s)
+				continue
+			}
+			v[k] = scrubValue(e)
+		}
+		return v
+	case []interface{}:
+		for i, e := range v {
+			v[i] = scrubValue(e)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// scrubSecretField scrubs the value of a secret field. For a URL (e.g. the "secretUri" of a key vault secret, or the
+// "sasUri"), only the secret query parameters and the user info are scrubbed, as the rest is not secret, and might be
+// needed when replaying. Otherwise, the whole value is scrubbed.
+func scrubSecretField(v string) string {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return redacted
+	}
+	if u.User != nil {
+		u.User = url.User(redacted)
+	}
+	scrubQuery(u)
+	return u.String()
+}
+
+// replayCredential is the credential used in replay mode, as the authentication is not recorded.
+type replayCredential struct{}
+
+func (replayCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: redacted, ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
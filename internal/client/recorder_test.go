@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorderRecordAndReplay(t *testing.T) {
+	const respBody = `{"properties":{"accessToken":"secret-token","nested":[{"connectionString":"secret-conn","name":"foo"}]}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(respBody))
+	}))
+	defer server.Close()
+
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+	newRequest := func() *http.Request {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/foo?api-version=2021-04-01", strings.NewReader(`{"password":"secret-password"}`))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer secret-token")
+		return req
+	}
+
+	// Record
+	r, err := NewRecorder(RecorderModeRecord, cassette)
+	require.NoError(t, err)
+	resp, err := r.Do(newRequest())
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	// The response returned to the caller is not scrubbed.
+	require.Equal(t, respBody, string(b))
+
+	b, err = os.ReadFile(cassette)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), "secret")
+	var c Cassette
+	require.NoError(t, json.Unmarshal(b, &c))
+	require.Len(t, c.Interactions, 1)
+	interaction := c.Interactions[0]
+	require.Equal(t, []string{redacted}, interaction.Request.Header["Authorization"])
+	require.Equal(t, `{"password":"REDACTED"}`, interaction.Request.Body)
+	require.Equal(t, []string{redacted}, interaction.Response.Header["Set-Cookie"])
+	require.Equal(t, `{"properties":{"accessToken":"REDACTED","nested":[{"connectionString":"REDACTED","name":"foo"}]}}`, interaction.Response.Body)
+
+	// Replay, without the server
+	server.Close()
+	r, err = NewRecorder(RecorderModeReplay, cassette)
+	require.NoError(t, err)
+	resp, err = r.Do(newRequest())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	b, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, interaction.Response.Body, string(b))
+
+	// Each interaction is only replayed once.
+	_, err = r.Do(newRequest())
+	require.Error(t, err)
+}
+
+func TestRecorderRewriteSubscriptionIdAndScrubQuery(t *testing.T) {
+	const realSubId = "12345678-90ab-cdef-1234-567890abcdef"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "https://management.azure.com/subscriptions/"+realSubId+"/operationResults/op")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"id":"/subscriptions/` + strings.ToUpper(realSubId) + `/resourceGroups/rg","properties":{"subscriptionId":"` + realSubId + `","secretUri":"https://kv.vault.azure.net/secrets/s/1","sasUri":"https://sa.blob.core.windows.net/c?sig=secret-sig"}}`))
+	}))
+	defer server.Close()
+
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+	newRequest := func(subId, sig string) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/subscriptions/"+subId+"/resourceGroups/rg?api-version=2021-04-01&sig="+sig+"&sv=2021-06-08", nil)
+		require.NoError(t, err)
+		return req
+	}
+
+	// Record
+	r, err := NewRecorder(RecorderModeRecord, cassette)
+	require.NoError(t, err)
+	_, err = r.Do(newRequest(realSubId, "secret-sig"))
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(cassette)
+	require.NoError(t, err)
+	require.NotContains(t, strings.ToLower(string(b)), realSubId)
+	require.NotContains(t, string(b), "secret-sig")
+	var c Cassette
+	require.NoError(t, json.Unmarshal(b, &c))
+	require.Len(t, c.Interactions, 1)
+	interaction := c.Interactions[0]
+	require.Equal(t, server.URL+"/subscriptions/"+RecordedSubscriptionId+"/resourceGroups/rg?api-version=2021-04-01&sig=REDACTED&sv=2021-06-08", interaction.Request.URL)
+	require.Equal(t, []string{"https://management.azure.com/subscriptions/" + RecordedSubscriptionId + "/operationResults/op"}, interaction.Response.Header["Location"])
+	// The URL valued secret fields only have the secrets in the query scrubbed.
+	require.Equal(t, `{"id":"/subscriptions/`+RecordedSubscriptionId+`/resourceGroups/rg","properties":{"sasUri":"https://sa.blob.core.windows.net/c?sig=REDACTED","secretUri":"https://kv.vault.azure.net/secrets/s/1","subscriptionId":"`+RecordedSubscriptionId+`"}}`, interaction.Response.Body)
+
+	// Replay with the recorded subscription id, and a different signature
+	server.Close()
+	r, err = NewRecorder(RecorderModeReplay, cassette)
+	require.NoError(t, err)
+	resp, err := r.Do(newRequest(RecordedSubscriptionId, "other-sig"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestClientBuilderReplay(t *testing.T) {
+	const subId = "00000000-0000-0000-0000-000000000000"
+	t.Setenv(RecorderModeEnvVar, RecorderModeReplay)
+	t.Setenv(RecorderCassetteEnvVar, filepath.Join("testdata", "cassette_list_and_keyvault.json"))
+
+	b, err := NewClientBuilder(AuthDefault)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	resClient, err := b.NewResourcesClient(subId)
+	require.NoError(t, err)
+	var ids []string
+	pager := resClient.NewListByResourceGroupPager("rg", nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		require.NoError(t, err)
+		for _, res := range page.Value {
+			ids = append(ids, *res.ID)
+		}
+	}
+	require.Equal(t, []string{
+		"/subscriptions/" + subId + "/resourceGroups/rg/providers/Microsoft.KeyVault/vaults/kv",
+		"/subscriptions/" + subId + "/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet",
+	}, ids)
+
+	keysClient, err := b.NewKeyvaultKeysClient(subId)
+	require.NoError(t, err)
+	resp, err := keysClient.Get(ctx, "rg", "kv", "key", nil)
+	require.NoError(t, err)
+	require.Equal(t, "https://kv.vault.azure.net/keys/key/00000000000000000000000000000000", *resp.Key.Properties.KeyURIWithVersion)
+}
@@ -15,17 +15,40 @@ type CommonConfig struct {
 	Append         bool
 	DevProvider    bool
 	BatchMode      bool
-	BackendType    string
-	BackendConfig  []string
+	Verify         bool
+	// The max iterations of auto adding the residual diff to lifecycle.ignore_changes, 0 means disabled.
+	AutoIgnoreChanges int
+	// Whether to generate data sources instead of importing the resources.
+	DataSource       bool
+	BackendType      string
+	BackendConfig    []string
+	Annotate         bool
+	AztfyVersion     string
+	Transformers     []string
+	RewriteRulesFile string
+	MockClient       bool
+	// The scenario file that drives the mock client, empty means the default scenario.
+	MockScenarioFile string
+	// The authentication method, which is one of client.PossibleAuthValues(). Empty means client.AuthDefault.
+	Auth string
 }
 
 type RgConfig struct {
 	CommonConfig
 
-	ResourceGroupName   string
-	ResourceMapping     resmap.ResourceMapping
-	ResourceNamePattern string
-	MockClient          bool
+	ResourceGroupName    string
+	ResourceMapping      resmap.ResourceMapping
+	ResourceNamePattern  string
+	Graph                bool
+	ManagedResourcesFile string
+	// The strategy to discover the resources in the resource group, empty means the default one.
+	Discovery string
+	// The max amount of resources to export in each ARM template export request, 0 means exporting all at once.
+	ExportChunkSize int
+	// The ARM template file to read the resources from, instead of discovering them from Azure.
+	ArmTemplateFile string
+	// Whether to generate data sources for the resources that are depended on, but not imported.
+	ExternalDataSources bool
 }
 
 func (RgConfig) isConfig() {}
@@ -0,0 +1,133 @@
+package meta
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Azure/aztfy/internal/armtemplate"
+	"github.com/Azure/aztfy/internal/resmap"
+	"github.com/Azure/aztfy/internal/tfaddr"
+)
+
+// The keys of the provenance annotations, which are written as leading comments of each generated resource block, in form of:
+//
+//	# aztfy:<key> = <value>
+const (
+	AnnotationKeyAzureResourceId = "azure_resource_id"
+	AnnotationKeyTFResourceId    = "tf_resource_id"
+	AnnotationKeyARMType         = "arm_type"
+	AnnotationKeyAztfyVersion    = "aztfy_version"
+	AnnotationKeyProviderVersion = "provider_version"
+	AnnotationKeyTimestamp       = "timestamp"
+)
+
+const annotationPrefix = "# aztfy:"
+
+var (
+	annotationPattern    = regexp.MustCompile(`^\s*# aztfy:(\w+)\s*=\s*(.*)$`)
+	resourceBlockPattern = regexp.MustCompile(`^\s*resource\s+"([^"]+)"\s+"([^"]+)"\s*{`)
+)
+
+// Annotation is the provenance information of a generated resource block.
+type Annotation struct {
+	AzureResourceId string
+	TFResourceId    string
+	ARMType         string
+	AztfyVersion    string
+	ProviderVersion string
+	Timestamp       string
+}
+
+func newAnnotation(item ImportItem, aztfyVersion, providerVersion, timestamp string) Annotation {
+	azureId := item.AzureResourceID
+	if azureId == "" {
+		azureId = item.ResourceID
+	}
+	return Annotation{
+		AzureResourceId: azureId,
+		TFResourceId:    item.ResourceID,
+		ARMType:         armtemplate.ArmTypeOfId(azureId),
+		AztfyVersion:    aztfyVersion,
+		ProviderVersion: providerVersion,
+		Timestamp:       timestamp,
+	}
+}
+
+// String returns the comment lines of the annotation. Empty fields are omitted.
+func (a Annotation) String() string {
+	var lines []string
+	for _, kv := range [][2]string{
+		{AnnotationKeyAzureResourceId, a.AzureResourceId},
+		{AnnotationKeyTFResourceId, a.TFResourceId},
+		{AnnotationKeyARMType, a.ARMType},
+		{AnnotationKeyAztfyVersion, a.AztfyVersion},
+		{AnnotationKeyProviderVersion, a.ProviderVersion},
+		{AnnotationKeyTimestamp, a.Timestamp},
+	} {
+		if kv[1] == "" {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s%s = %s\n", annotationPrefix, kv[0], kv[1]))
+	}
+	return strings.Join(lines, "")
+}
+
+// ResourceMappingFromAnnotation rebuilds the resource mapping from the provenance annotations of the resource blocks
+// defined in the Terraform configuration files under the specified directory.
+func ResourceMappingFromAnnotation(dir string) (resmap.ResourceMapping, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading directory %s: %v", dir, err)
+	}
+	m := resmap.ResourceMapping{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".tf" {
+			continue
+		}
+		if err := resourceMappingFromAnnotationInFile(filepath.Join(dir, entry.Name()), m); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func resourceMappingFromAnnotationInFile(path string, m resmap.ResourceMapping) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening %s: %v", path, err)
+	}
+	defer f.Close()
+
+	annotations := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := annotationPattern.FindStringSubmatch(line); match != nil {
+			annotations[match[1]] = strings.TrimSpace(match[2])
+			continue
+		}
+		if match := resourceBlockPattern.FindStringSubmatch(line); match != nil {
+			id := annotations[AnnotationKeyTFResourceId]
+			if id == "" {
+				id = annotations[AnnotationKeyAzureResourceId]
+			}
+			if id != "" {
+				addr := tfaddr.TFAddr{Type: match[1], Name: match[2]}
+				if oaddr, ok := m[id]; ok && oaddr != addr {
+					return fmt.Errorf("resource %s is annotated on both %s and %s", id, oaddr, addr)
+				}
+				m[id] = addr
+			}
+		}
+		// Annotations only apply to the resource block right after them.
+		annotations = map[string]string{}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading file %s: %v", path, err)
+	}
+	return nil
+}
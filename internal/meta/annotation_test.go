@@ -0,0 +1,54 @@
+package meta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/aztfy/internal/resmap"
+	"github.com/Azure/aztfy/internal/tfaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotationString(t *testing.T) {
+	item := ImportItem{
+		ResourceID: "/subscriptions/123/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1/subnets/subnet1",
+	}
+	expect := `# aztfy:azure_resource_id = /subscriptions/123/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1/subnets/subnet1
+# aztfy:tf_resource_id = /subscriptions/123/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1/subnets/subnet1
+# aztfy:arm_type = Microsoft.Network/virtualNetworks/subnets
+# aztfy:aztfy_version = v0.1.0
+# aztfy:timestamp = 2022-08-01T00:00:00Z
+`
+	require.Equal(t, expect, newAnnotation(item, "v0.1.0", "", "2022-08-01T00:00:00Z").String())
+}
+
+func TestResourceMappingFromAnnotation(t *testing.T) {
+	dir := t.TempDir()
+	vnet := ImportItem{
+		ResourceID: "/subscriptions/123/resourceGroups/rg1/providers/Microsoft.Network/virtualNetworks/vnet1",
+	}
+	cert := ImportItem{
+		ResourceID:      "https://kv1.vault.azure.net/certificates/cert1/000",
+		AzureResourceID: "/subscriptions/123/resourceGroups/rg1/providers/Microsoft.KeyVault/vaults/kv1/certificates/cert1",
+	}
+	main := newAnnotation(vnet, "v0.1.0", "3.16.0", "2022-08-01T00:00:00Z").String() + `resource "azurerm_virtual_network" "test" {
+  name = "vnet1"
+}
+
+resource "azurerm_resource_group" "not_annotated" {
+}
+
+` + newAnnotation(cert, "v0.1.0", "3.16.0", "2022-08-01T00:00:00Z").String() + `resource "azurerm_key_vault_certificate" "test" {
+}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte(main), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "readme.txt"), []byte(newAnnotation(vnet, "", "", "").String()+`resource "foo" "bar" {}`), 0644))
+
+	m, err := ResourceMappingFromAnnotation(dir)
+	require.NoError(t, err)
+	require.Equal(t, resmap.ResourceMapping{
+		vnet.ResourceID: tfaddr.TFAddr{Type: "azurerm_virtual_network", Name: "test"},
+		cert.ResourceID: tfaddr.TFAddr{Type: "azurerm_key_vault_certificate", Name: "test"},
+	}, m)
+}
@@ -10,7 +10,9 @@ type ConfigInfos []ConfigInfo
 
 type ConfigInfo struct {
 	ImportItem
-	hcl *hclwrite.File
+	// Whether this is a data source, which is referenced by the resources but not imported.
+	DataSource bool
+	hcl        *hclwrite.File
 }
 
 func (cfg ConfigInfo) DumpHCL(w io.Writer) (int, error) {
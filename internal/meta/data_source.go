@@ -0,0 +1,316 @@
+package meta
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Azure/aztfy/internal/tfaddr"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/magodo/armid"
+	"github.com/magodo/aztft/aztft"
+	"github.com/magodo/tfadd/providers/azurerm"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// dataSource is the data source of a resource that is depended on, but not imported (e.g. it resides in another
+// resource group), which is referenced instead of the hard-coded resource id.
+type dataSource struct {
+	AzureId string
+	TFAddr  tfaddr.TFAddr
+
+	// The arguments that identify the resource, e.g. "name" and "resource_group_name".
+	args map[string]string
+}
+
+var dataSourceBlockPattern = regexp.MustCompile(`^\s*data\s+"([^"]+)"\s+"([^"]+)"\s*{`)
+
+// workspaceDataSources returns the addresses of the data sources defined in the Terraform configuration files under the
+// directory.
+func workspaceDataSources(dir string) (map[tfaddr.TFAddr]bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading directory %s: %v", dir, err)
+	}
+	addrs := map[tfaddr.TFAddr]bool{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".tf" {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("opening %s: %v", path, err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if match := dataSourceBlockPattern.FindStringSubmatch(scanner.Text()); match != nil {
+				addrs[tfaddr.TFAddr{Type: match[1], Name: match[2]}] = true
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading file %s: %v", path, err)
+		}
+	}
+	return addrs, nil
+}
+
+// newDataSource builds the data source of the Azure resource, whose type is queried via aztft.
+func newDataSource(azureId, dsName string) (*dataSource, error) {
+	rts, err := aztft.QueryType(azureId, false)
+	if err != nil {
+		return nil, fmt.Errorf("querying the resource type of %s: %v", azureId, err)
+	}
+	if len(rts) != 1 {
+		return nil, fmt.Errorf("expect exactly one resource type of %s, got=%d", azureId, len(rts))
+	}
+	return newDataSourceOfType(azureId, rts[0], dsName)
+}
+
+// newDataSourceOfType builds the data source of the Azure resource, which is of the TF resource type rt. Only the
+// resource types in dataSourceTypes are supported, whose data source has the same type as the resource, and is
+// identified by the "name" alike arguments that are required to create the resource (e.g. "name",
+// "resource_group_name", "virtual_network_name"), together with the ids of its parents (e.g. "key_vault_id"), whose
+// values are derived from the resource id.
+func newDataSourceOfType(azureId, rt, dsName string) (*dataSource, error) {
+	if !dataSourceTypes[rt] {
+		return nil, fmt.Errorf("%s has no data source", rt)
+	}
+	id, err := armid.ParseResourceId(azureId)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the resource id %s: %v", azureId, err)
+	}
+	rsch, ok := azurerm.ProviderSchemaInfo.ResourceSchemas[rt]
+	if !ok {
+		return nil, fmt.Errorf("no schema found for %s", rt)
+	}
+
+	// The name of the resource, and the names of its ancestors keyed by the snake cased singular resource type.
+	var name string
+	names := map[string]string{}
+	if rg, ok := id.RootScope().(*armid.ResourceGroup); ok {
+		names["resource_group"] = rg.Name
+	}
+	// The ids of the ancestors (from the nearest one), and their snake cased singular resource types.
+	var parentIds, parentTypes []string
+	if rg, ok := id.(*armid.ResourceGroup); ok {
+		name = rg.Name
+	} else {
+		types, typeNames := id.Types(), id.Names()
+		for i := range typeNames {
+			names[snakeSingular(types[i])] = typeNames[i]
+		}
+		name = typeNames[len(typeNames)-1]
+		for p := id.Parent(); p != nil; p = p.Parent() {
+			parentIds = append(parentIds, p.String())
+			parentTypes = append(parentTypes, snakeSingular(p.Types()[len(p.Types())-1]))
+		}
+	}
+
+	args := map[string]string{}
+	for attrName, attr := range rsch.Block.Attributes {
+		if !attr.Required || !attr.ForceNew {
+			continue
+		}
+		var v string
+		switch {
+		case attrName == "name":
+			v = name
+		case strings.HasSuffix(attrName, "_name"):
+			v = names[strings.TrimSuffix(attrName, "_name")]
+		case strings.HasSuffix(attrName, "_id"):
+			// The id of the ancestor, whose type is the same as, or the suffix of, the argument (e.g. "key_vault_id"
+			// refers to the ancestor of type "vaults").
+			t := strings.TrimSuffix(attrName, "_id")
+			for i, pt := range parentTypes {
+				if t == pt || strings.HasSuffix(t, "_"+pt) {
+					v = parentIds[i]
+					break
+				}
+			}
+		default:
+			continue
+		}
+		if v == "" {
+			return nil, fmt.Errorf("can't derive %q of the data source %s from %s", attrName, rt, azureId)
+		}
+		args[attrName] = v
+	}
+	if _, ok := args["name"]; !ok {
+		return nil, fmt.Errorf("the data source %s is not identified by name", rt)
+	}
+
+	return &dataSource{
+		AzureId: azureId,
+		TFAddr:  tfaddr.TFAddr{Type: rt, Name: dsName},
+		args:    args,
+	}, nil
+}
+
+// Ref returns the reference to the data source, e.g. "data.azurerm_virtual_network.res-ext-0".
+func (ds dataSource) Ref() string {
+	return "data." + ds.TFAddr.String()
+}
+
+// IdTraversal returns the traversal to the id of the data source.
+func (ds dataSource) IdTraversal() hcl.Traversal {
+	return hcl.Traversal{
+		hcl.TraverseRoot{Name: "data"},
+		hcl.TraverseAttr{Name: ds.TFAddr.Type},
+		hcl.TraverseAttr{Name: ds.TFAddr.Name},
+		hcl.TraverseAttr{Name: "id"},
+	}
+}
+
+func (ds dataSource) configInfo() ConfigInfo {
+	f := hclwrite.NewEmptyFile()
+	body := f.Body().AppendNewBlock("data", []string{ds.TFAddr.Type, ds.TFAddr.Name}).Body()
+	var keys []string
+	for k := range ds.args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		body.SetAttributeValue(k, cty.StringVal(ds.args[k]))
+	}
+	return ConfigInfo{
+		ImportItem: ImportItem{
+			ResourceID:      ds.AzureId,
+			AzureResourceID: ds.AzureId,
+			TFAddr:          ds.TFAddr,
+		},
+		DataSource: true,
+		hcl:        f,
+	}
+}
+
+// GenerateDataSourceCfg generates the data sources, instead of the resources, of the items in the list, without
+// importing them. The skipped items and the synthetic items (e.g. the association resources, which have no data
+// source) are ignored. The ImportError of the items whose data source can't be built is set, while the others are
+// still generated.
+func (meta Meta) GenerateDataSourceCfg(l ImportList) error {
+	var cfgs ConfigInfos
+	for i, item := range l {
+		if item.Skip() || item.Synthetic {
+			continue
+		}
+		ds, err := newDataSourceOfType(item.AzureResourceID, item.TFAddr.Type, item.TFAddr.Name)
+		if err != nil {
+			l[i].ImportError = fmt.Errorf("building the data source: %v", err)
+			continue
+		}
+		cfg := ds.configInfo()
+		cfg.ResourceID = item.ResourceID
+		cfgs = append(cfgs, cfg)
+	}
+	return meta.generateConfig(cfgs)
+}
+
+// snakeSingular converts the ARM resource type to the snake cased singular form, e.g. "virtualNetworks" to
+// "virtual_network".
+func snakeSingular(rt string) string {
+	switch {
+	case strings.HasSuffix(rt, "ies"):
+		rt = strings.TrimSuffix(rt, "ies") + "y"
+	case strings.HasSuffix(rt, "sses"), strings.HasSuffix(rt, "xes"):
+		rt = strings.TrimSuffix(rt, "es")
+	case strings.HasSuffix(rt, "s"):
+		rt = strings.TrimSuffix(rt, "s")
+	}
+	var sb strings.Builder
+	for i, r := range rt {
+		if unicode.IsUpper(r) {
+			if i != 0 {
+				sb.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// dataSourceTypes are the TF resource types that are verified to have a data source of the same type, which is
+// identified by the same arguments as the resource. The other resource types (e.g. the associations, the role
+// assignments and the virtual network peerings) either have no data source, or have one identified differently.
+var dataSourceTypes = map[string]bool{
+	"azurerm_api_management":               true,
+	"azurerm_app_service_plan":             true,
+	"azurerm_application_gateway":          true,
+	"azurerm_application_insights":         true,
+	"azurerm_application_security_group":   true,
+	"azurerm_availability_set":             true,
+	"azurerm_batch_account":                true,
+	"azurerm_container_registry":           true,
+	"azurerm_cosmosdb_account":             true,
+	"azurerm_data_factory":                 true,
+	"azurerm_databricks_workspace":         true,
+	"azurerm_dedicated_host_group":         true,
+	"azurerm_disk_encryption_set":          true,
+	"azurerm_dns_zone":                     true,
+	"azurerm_eventhub":                     true,
+	"azurerm_eventhub_namespace":           true,
+	"azurerm_express_route_circuit":        true,
+	"azurerm_firewall":                     true,
+	"azurerm_firewall_policy":              true,
+	"azurerm_image":                        true,
+	"azurerm_key_vault":                    true,
+	"azurerm_key_vault_certificate":        true,
+	"azurerm_key_vault_key":                true,
+	"azurerm_key_vault_secret":             true,
+	"azurerm_kubernetes_cluster":           true,
+	"azurerm_lb":                           true,
+	"azurerm_linux_function_app":           true,
+	"azurerm_linux_web_app":                true,
+	"azurerm_local_network_gateway":        true,
+	"azurerm_log_analytics_workspace":      true,
+	"azurerm_managed_disk":                 true,
+	"azurerm_monitor_action_group":         true,
+	"azurerm_mssql_database":               true,
+	"azurerm_mssql_elasticpool":            true,
+	"azurerm_mssql_server":                 true,
+	"azurerm_mysql_flexible_server":        true,
+	"azurerm_mysql_server":                 true,
+	"azurerm_nat_gateway":                  true,
+	"azurerm_network_ddos_protection_plan": true,
+	"azurerm_network_interface":            true,
+	"azurerm_network_security_group":       true,
+	"azurerm_network_watcher":              true,
+	"azurerm_postgresql_flexible_server":   true,
+	"azurerm_postgresql_server":            true,
+	"azurerm_private_dns_zone":             true,
+	"azurerm_proximity_placement_group":    true,
+	"azurerm_public_ip":                    true,
+	"azurerm_public_ip_prefix":             true,
+	"azurerm_redis_cache":                  true,
+	"azurerm_resource_group":               true,
+	"azurerm_route_table":                  true,
+	"azurerm_search_service":               true,
+	"azurerm_service_plan":                 true,
+	"azurerm_servicebus_namespace":         true,
+	"azurerm_servicebus_queue":             true,
+	"azurerm_servicebus_topic":             true,
+	"azurerm_shared_image_gallery":         true,
+	"azurerm_signalr_service":              true,
+	"azurerm_snapshot":                     true,
+	"azurerm_storage_account":              true,
+	"azurerm_storage_container":            true,
+	"azurerm_storage_share":                true,
+	"azurerm_subnet":                       true,
+	"azurerm_user_assigned_identity":       true,
+	"azurerm_virtual_hub":                  true,
+	"azurerm_virtual_machine":              true,
+	"azurerm_virtual_network":              true,
+	"azurerm_virtual_network_gateway":      true,
+	"azurerm_virtual_wan":                  true,
+	"azurerm_windows_function_app":         true,
+	"azurerm_windows_web_app":              true,
+}
@@ -0,0 +1,247 @@
+package meta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/aztfy/internal/armtemplate"
+	"github.com/Azure/aztfy/internal/tfaddr"
+	"github.com/magodo/tfadd/providers/azurerm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDataSource(t *testing.T) {
+	const rgId = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg"
+	cases := []struct {
+		name   string
+		id     string
+		expect *dataSource
+		err    bool
+	}{
+		{
+			name: "resource group",
+			id:   rgId,
+			expect: &dataSource{
+				AzureId: rgId,
+				TFAddr:  tfaddr.TFAddr{Type: "azurerm_resource_group", Name: "ext-0"},
+				args:    map[string]string{"name": "rg"},
+			},
+		},
+		{
+			name: "virtual network",
+			id:   rgId + "/providers/Microsoft.Network/virtualNetworks/vnet",
+			expect: &dataSource{
+				AzureId: rgId + "/providers/Microsoft.Network/virtualNetworks/vnet",
+				TFAddr:  tfaddr.TFAddr{Type: "azurerm_virtual_network", Name: "ext-0"},
+				args:    map[string]string{"name": "vnet", "resource_group_name": "rg"},
+			},
+		},
+		{
+			name: "subnet",
+			id:   rgId + "/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet",
+			expect: &dataSource{
+				AzureId: rgId + "/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet",
+				TFAddr:  tfaddr.TFAddr{Type: "azurerm_subnet", Name: "ext-0"},
+				args:    map[string]string{"name": "subnet", "resource_group_name": "rg", "virtual_network_name": "vnet"},
+			},
+		},
+		{
+			name: "storage share",
+			id:   rgId + "/providers/Microsoft.Storage/storageAccounts/sa/fileServices/default/shares/share",
+			expect: &dataSource{
+				AzureId: rgId + "/providers/Microsoft.Storage/storageAccounts/sa/fileServices/default/shares/share",
+				TFAddr:  tfaddr.TFAddr{Type: "azurerm_storage_share", Name: "ext-0"},
+				args:    map[string]string{"name": "share", "storage_account_name": "sa"},
+			},
+		},
+		{
+			name: "key vault secret",
+			id:   rgId + "/providers/Microsoft.KeyVault/vaults/kv/secrets/secret",
+			expect: &dataSource{
+				AzureId: rgId + "/providers/Microsoft.KeyVault/vaults/kv/secrets/secret",
+				TFAddr:  tfaddr.TFAddr{Type: "azurerm_key_vault_secret", Name: "ext-0"},
+				args:    map[string]string{"name": "secret", "key_vault_id": rgId + "/providers/Microsoft.KeyVault/vaults/kv"},
+			},
+		},
+		{
+			name: "mssql database",
+			id:   rgId + "/providers/Microsoft.Sql/servers/server/databases/db",
+			expect: &dataSource{
+				AzureId: rgId + "/providers/Microsoft.Sql/servers/server/databases/db",
+				TFAddr:  tfaddr.TFAddr{Type: "azurerm_mssql_database", Name: "ext-0"},
+				args:    map[string]string{"name": "db", "server_id": rgId + "/providers/Microsoft.Sql/servers/server"},
+			},
+		},
+		{
+			name: "virtual network peering, which has no data source",
+			id:   rgId + "/providers/Microsoft.Network/virtualNetworks/vnet/virtualNetworkPeerings/peering",
+			err:  true,
+		},
+		{
+			name: "invalid resource id",
+			id:   "foo",
+			err:  true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ds, err := newDataSource(c.id, "ext-0")
+			if c.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expect, ds)
+		})
+	}
+}
+
+func TestNewDataSourceOfType_parentIdNotFound(t *testing.T) {
+	const id = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg/providers/Microsoft.Foo/bars/bar/secrets/secret"
+	_, err := newDataSourceOfType(id, "azurerm_key_vault_secret", "ext-0")
+	require.EqualError(t, err, `can't derive "key_vault_id" of the data source azurerm_key_vault_secret from `+id)
+}
+
+func TestDataSourceTypes(t *testing.T) {
+	for rt := range dataSourceTypes {
+		_, ok := azurerm.ProviderSchemaInfo.ResourceSchemas[rt]
+		require.True(t, ok, "no schema found for %s", rt)
+	}
+}
+
+func TestDataSourceConfigInfo(t *testing.T) {
+	ds := dataSource{
+		AzureId: "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet",
+		TFAddr:  tfaddr.TFAddr{Type: "azurerm_virtual_network", Name: "ext-0"},
+		args:    map[string]string{"resource_group_name": "rg", "name": "vnet"},
+	}
+	require.Equal(t, "data.azurerm_virtual_network.ext-0", ds.Ref())
+	cfg := ds.configInfo()
+	require.True(t, cfg.DataSource)
+	require.Equal(t, ds.AzureId, cfg.ResourceID)
+	require.Equal(t, `data "azurerm_virtual_network" "ext-0" {
+  name                = "vnet"
+  resource_group_name = "rg"
+}
+`, string(cfg.hcl.Bytes()))
+}
+
+func TestBuildDataSources(t *testing.T) {
+	const (
+		rgId       = "/subscriptions/123/resourceGroups/rg"
+		subnetId   = rgId + "/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet"
+		otherRgId  = "/subscriptions/123/resourceGroups/other"
+		vnetId     = otherRgId + "/providers/Microsoft.Network/virtualNetworks/vnet"
+		routeTblId = otherRgId + "/providers/Microsoft.Network/routeTables/rt"
+	)
+
+	cases := []struct {
+		name     string
+		existing string
+		expect   map[string]tfaddr.TFAddr
+	}{
+		{
+			name: "empty workspace",
+			expect: map[string]tfaddr.TFAddr{
+				routeTblId: {Type: "azurerm_route_table", Name: "foo-ext-0-bar"},
+				vnetId:     {Type: "azurerm_virtual_network", Name: "foo-ext-1-bar"},
+			},
+		},
+		{
+			name: "append to workspace",
+			existing: `data "azurerm_route_table" "foo-ext-0-bar" {
+  name                = "rt0"
+  resource_group_name = "other"
+}
+`,
+			expect: map[string]tfaddr.TFAddr{
+				routeTblId: {Type: "azurerm_route_table", Name: "foo-ext-1-bar"},
+				vnetId:     {Type: "azurerm_virtual_network", Name: "foo-ext-2-bar"},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		dir := t.TempDir()
+		if c.existing != "" {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte(c.existing), 0644), c.name)
+		}
+		meta := MetaRgImpl{
+			Meta: Meta{
+				outdir: dir,
+				empty:  c.existing == "",
+			},
+			resourceNamePrefix: "foo-",
+			resourceNameSuffix: "-bar",
+			resources: armtemplate.TFResources{
+				subnetId: {AzureId: subnetId, TFId: subnetId, DependsOn: []string{vnetId, routeTblId}},
+			},
+		}
+		configs := ConfigInfos{{ImportItem: ImportItem{ResourceID: subnetId}}}
+		dataSources, err := meta.buildDataSources(configs, map[string]ConfigInfo{subnetId: configs[0]})
+		require.NoError(t, err, c.name)
+		actual := map[string]tfaddr.TFAddr{}
+		for id, ds := range dataSources {
+			actual[id] = ds.TFAddr
+		}
+		require.Equal(t, c.expect, actual, c.name)
+	}
+}
+
+func TestMetaGenerateDataSourceCfg(t *testing.T) {
+	meta, _ := newTestMeta(t, true)
+	const rgId = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/rg"
+	l := ImportList{
+		{
+			ResourceID:      rgId,
+			AzureResourceID: rgId,
+			TFAddr:          tfaddr.TFAddr{Type: "azurerm_resource_group", Name: "res-0"},
+		},
+		{
+			ResourceID:      rgId + "/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet",
+			AzureResourceID: rgId + "/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet",
+			TFAddr:          tfaddr.TFAddr{Type: "azurerm_subnet", Name: "res-1"},
+		},
+		{
+			ResourceID:      rgId + "/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet",
+			AzureResourceID: rgId + "/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet",
+			TFAddr:          tfaddr.TFAddr{Type: "azurerm_subnet_route_table_association", Name: "res-2"},
+			Synthetic:       true,
+		},
+		{
+			ResourceID:      rgId + "/providers/Microsoft.Foo/bars/bar",
+			AzureResourceID: rgId + "/providers/Microsoft.Foo/bars/bar",
+			TFAddr:          tfaddr.TFAddr{Type: "azurerm_foo", Name: "res-3"},
+		},
+		{
+			ResourceID:      rgId + "/providers/Microsoft.Foo/bazs/baz",
+			AzureResourceID: rgId + "/providers/Microsoft.Foo/bazs/baz",
+		},
+	}
+	require.NoError(t, meta.GenerateDataSourceCfg(l))
+
+	require.NoError(t, l[0].ImportError)
+	require.NoError(t, l[1].ImportError)
+	require.NoError(t, l[2].ImportError)
+	require.Error(t, l[3].ImportError)
+	require.NoError(t, l[4].ImportError)
+	for _, item := range l {
+		require.False(t, item.Imported)
+	}
+
+	b, err := os.ReadFile(filepath.Join(meta.outdir, "main.tf"))
+	require.NoError(t, err)
+	require.Equal(t, `data "azurerm_resource_group" "res-0" {
+  name = "rg"
+}
+
+data "azurerm_subnet" "res-1" {
+  name                 = "subnet"
+  resource_group_name  = "rg"
+  virtual_network_name = "vnet"
+}
+
+`, string(b))
+}
@@ -0,0 +1,423 @@
+package meta
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/aztfy/internal/armtemplate"
+	"github.com/Azure/aztfy/internal/client"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+)
+
+// The strategies to discover the resources in a resource group.
+const (
+	// DiscoveryAuto exports the ARM template of the resource group, and falls back to DiscoveryList if the export fails.
+	DiscoveryAuto = "auto"
+	// DiscoveryExport exports the ARM template of the resource group.
+	DiscoveryExport = "export"
+	// DiscoveryList lists the resources in the resource group, and gets the properties of each resource.
+	DiscoveryList = "list"
+)
+
+// PossibleDiscoveryValues returns the possible values of the discovery strategy.
+func PossibleDiscoveryValues() []string {
+	return []string{DiscoveryAuto, DiscoveryExport, DiscoveryList}
+}
+
+// discoverer discovers the resources in a resource group, in form of the ARM template.
+type discoverer interface {
+	discover(ctx context.Context, rg string) (*discovery, error)
+}
+
+type discovery struct {
+	template *armtemplate.Template
+
+	// The raw ARM template as returned by ARM (or read from file), if any.
+	raw interface{}
+
+	// The resources that exist in the resource group, but failed to be exported into the ARM template (i.e. without
+	// properties and dependencies). Key is the lower cased Azure resource id, value is the export error message.
+	exportErrors map[string]string
+}
+
+// newDiscoverer creates the discoverer of the strategy. The exportChunkSize, if positive, makes the ARM template to be
+// exported in chunks of the resources listed in the resource group.
+func newDiscoverer(strategy string, exportChunkSize int, b *client.ClientBuilder, subscriptionId string) (discoverer, error) {
+	switch strategy {
+	case DiscoveryExport:
+		return newExportDiscoverer(b, subscriptionId, exportChunkSize)
+	case DiscoveryList:
+		return newListDiscoverer(b, subscriptionId)
+	case DiscoveryAuto, "":
+		export, err := newExportDiscoverer(b, subscriptionId, exportChunkSize)
+		if err != nil {
+			return nil, err
+		}
+		list, err := newListDiscoverer(b, subscriptionId)
+		if err != nil {
+			return nil, err
+		}
+		return fallbackDiscoverer{export, list}, nil
+	default:
+		return nil, fmt.Errorf("unknown discovery strategy %q, expect one of %v", strategy, PossibleDiscoveryValues())
+	}
+}
+
+// fallbackDiscoverer tries each discoverer in order, until one succeeds.
+type fallbackDiscoverer []discoverer
+
+func (d fallbackDiscoverer) discover(ctx context.Context, rg string) (*discovery, error) {
+	var errs []string
+	for _, dd := range d {
+		result, err := dd.discover(ctx, rg)
+		if err == nil {
+			return result, nil
+		}
+		log.Printf("Discovering resources failed, trying the next discovery strategy: %v\n", err)
+		errs = append(errs, err.Error())
+	}
+	return nil, fmt.Errorf("all discovery strategies failed: %s", strings.Join(errs, "; "))
+}
+
+// exportDiscoverer exports the ARM template of the resource group.
+type exportDiscoverer struct {
+	client *armresources.ResourceGroupsClient
+
+	// The resources client is used to list the resources to be exported in chunks, when chunkSize is positive.
+	resClient *armresources.Client
+	chunkSize int
+}
+
+func newExportDiscoverer(b *client.ClientBuilder, subscriptionId string, chunkSize int) (discoverer, error) {
+	client, err := b.NewResourceGroupClient(subscriptionId)
+	if err != nil {
+		return nil, fmt.Errorf("building resource group client: %v", err)
+	}
+	d := exportDiscoverer{client: client, chunkSize: chunkSize}
+	if chunkSize > 0 {
+		d.resClient, err = b.NewResourcesClient(subscriptionId)
+		if err != nil {
+			return nil, fmt.Errorf("building resources client: %v", err)
+		}
+	}
+	return d, nil
+}
+
+// discover exports the ARM template of the resource group. If the export partially fails, the resources that failed
+// to export are added to the template without properties, and recorded in the export errors.
+//
+// To avoid hitting the limits of ARM on exporting large resource groups, the resources can be exported in chunks
+// (of the resources listed in the resource group), whose templates are then merged.
+func (d exportDiscoverer) discover(ctx context.Context, rg string) (*discovery, error) {
+	if d.chunkSize <= 0 {
+		return d.export(ctx, rg, []string{"*"})
+	}
+
+	ids, err := listResourceIds(ctx, d.resClient, rg)
+	if err != nil {
+		return nil, err
+	}
+	var tpls []armtemplate.Template
+	exportErrors := map[string]string{}
+	for i := 0; i < len(ids); i += d.chunkSize {
+		end := i + d.chunkSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		log.Printf("Exporting arm template of resource group %s for resources [%d, %d) of %d\n", rg, i, end, len(ids))
+		result, err := d.export(ctx, rg, ids[i:end])
+		if err != nil {
+			return nil, err
+		}
+		tpls = append(tpls, *result.template)
+		for k, v := range result.exportErrors {
+			exportErrors[k] = v
+		}
+	}
+	tpl := armtemplate.MergeTemplates(tpls...)
+	return &discovery{template: &tpl, exportErrors: exportErrors}, nil
+}
+
+// export exports the ARM template of the specified resources in the resource group, where "*" means all.
+func (d exportDiscoverer) export(ctx context.Context, rg string, resources []string) (*discovery, error) {
+	exportOpt := "SkipAllParameterization"
+	var resourceOpts []*string
+	for i := range resources {
+		resourceOpts = append(resourceOpts, &resources[i])
+	}
+	poller, err := d.client.BeginExportTemplate(ctx, rg, armresources.ExportTemplateRequest{
+		Resources: resourceOpts,
+		Options:   &exportOpt,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("exporting arm template of resource group %s: %w", rg, err)
+	}
+	resp, err := poller.PollUntilDone(ctx, &runtime.PollUntilDoneOptions{Frequency: 10 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("waiting for exporting arm template of resource group %s: %w", rg, err)
+	}
+
+	result := resp.ResourceGroupExportResult
+	if result.Template == nil {
+		if result.Error != nil {
+			return nil, fmt.Errorf("exporting arm template of resource group %s: %s", rg, exportErrorMessage(result.Error))
+		}
+		return nil, fmt.Errorf("exporting arm template of resource group %s: no template returned", rg)
+	}
+
+	// The response has been read into the ".Template" field as an interface, and the reader has been drained.
+	// As we have defined some (useful) types for the arm template, so we will do a json marshal then unmarshal here
+	// to convert the ".Template" (interface{}) into that artificial type.
+	raw, err := json.Marshal(result.Template)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling the template: %w", err)
+	}
+	var tpl armtemplate.Template
+	if err := json.Unmarshal(raw, &tpl); err != nil {
+		return nil, fmt.Errorf("unmarshalling the template: %w", err)
+	}
+
+	exportErrors := map[string]string{}
+	if result.Error != nil {
+		log.Printf("Exporting arm template of resource group %s completed with errors: %s\n", rg, exportErrorMessage(result.Error))
+		exportErrors = addExportFailures(&tpl, result.Error)
+	}
+	return &discovery{template: &tpl, raw: result.Template, exportErrors: exportErrors}, nil
+}
+
+// addExportFailures adds the resources that failed to be exported, which are the targets of the (nested) export errors,
+// into the template, and returns their error messages keyed by the lower cased resource id.
+func addExportFailures(tpl *armtemplate.Template, e *armresources.ErrorResponse) map[string]string {
+	failures := map[string]string{}
+	var walk func(e *armresources.ErrorResponse)
+	walk = func(e *armresources.ErrorResponse) {
+		if e == nil {
+			return
+		}
+		if e.Target != nil {
+			if id, err := armtemplate.ParseResourceId(*e.Target); err == nil && *id != armtemplate.ResourceGroupId {
+				k := strings.ToLower(strings.TrimSuffix(*e.Target, "/"))
+				if msg, ok := failures[k]; ok {
+					failures[k] = msg + "; " + exportErrorMessage(e)
+				} else {
+					failures[k] = exportErrorMessage(e)
+					if !templateContains(tpl, *id) {
+						tpl.Resources = append(tpl.Resources, armtemplate.Resource{ResourceId: *id})
+					}
+				}
+			}
+		}
+		for _, detail := range e.Details {
+			walk(detail)
+		}
+	}
+	walk(e)
+	return failures
+}
+
+func templateContains(tpl *armtemplate.Template, id armtemplate.ResourceId) bool {
+	for _, res := range tpl.Resources {
+		if strings.EqualFold(res.Type, id.Type) && strings.EqualFold(res.Name, id.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// exportErrorMessage returns the message of the export error in form of "<code>: <message>", without its details.
+func exportErrorMessage(e *armresources.ErrorResponse) string {
+	var code, msg string
+	if e.Code != nil {
+		code = *e.Code
+	}
+	if e.Message != nil {
+		msg = *e.Message
+	}
+	switch {
+	case code == "":
+		return msg
+	case msg == "":
+		return code
+	default:
+		return code + ": " + msg
+	}
+}
+
+// fileDiscoverer reads the ARM template from a file (e.g. exported via the "export-template" command), without calling Azure.
+// The export errors are read from the file next to it (see exportErrorsFile), if any.
+type fileDiscoverer struct {
+	path string
+}
+
+func (d fileDiscoverer) discover(_ context.Context, _ string) (*discovery, error) {
+	b, err := os.ReadFile(d.path)
+	if err != nil {
+		return nil, fmt.Errorf("reading the arm template file %s: %v", d.path, err)
+	}
+	var raw interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("unmarshalling the arm template file %s: %v", d.path, err)
+	}
+	var tpl armtemplate.Template
+	if err := json.Unmarshal(b, &tpl); err != nil {
+		return nil, fmt.Errorf("unmarshalling the arm template file %s: %v", d.path, err)
+	}
+
+	exportErrors := map[string]string{}
+	errorsFile := exportErrorsFile(d.path)
+	b, err = os.ReadFile(errorsFile)
+	switch {
+	case err == nil:
+		var failures map[string]string
+		if err := json.Unmarshal(b, &failures); err != nil {
+			return nil, fmt.Errorf("unmarshalling the export errors file %s: %v", errorsFile, err)
+		}
+		for _, target := range sortedKeys(failures) {
+			id, err := armtemplate.ParseResourceId(target)
+			if err != nil {
+				return nil, fmt.Errorf("parsing the resource id %q in the export errors file %s: %v", target, errorsFile, err)
+			}
+			exportErrors[strings.ToLower(target)] = failures[target]
+			if !templateContains(&tpl, *id) {
+				tpl.Resources = append(tpl.Resources, armtemplate.Resource{ResourceId: *id})
+			}
+		}
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("reading the export errors file %s: %v", errorsFile, err)
+	}
+	return &discovery{template: &tpl, raw: raw, exportErrors: exportErrors}, nil
+}
+
+// exportErrorsFile returns the path of the file recording the export errors of the ARM template file (e.g.
+// "aztfyArmTemplate.errors.json" for "aztfyArmTemplate.json"), keyed by the ids of the resources that failed to be
+// exported.
+func exportErrorsFile(templatePath string) string {
+	return strings.TrimSuffix(templatePath, filepath.Ext(templatePath)) + ".errors.json"
+}
+
+// listDiscoverer lists the resources in the resource group, and gets the properties of each resource.
+// Comparing to the exported ARM template, the nested resources (e.g. subnets) are not listed, and the dependencies
+// are only inferred from the properties.
+type listDiscoverer struct {
+	client          *armresources.Client
+	providersClient *armresources.ProvidersClient
+}
+
+func newListDiscoverer(b *client.ClientBuilder, subscriptionId string) (discoverer, error) {
+	client, err := b.NewResourcesClient(subscriptionId)
+	if err != nil {
+		return nil, fmt.Errorf("building resources client: %v", err)
+	}
+	providersClient, err := b.NewProvidersClient(subscriptionId)
+	if err != nil {
+		return nil, fmt.Errorf("building providers client: %v", err)
+	}
+	return listDiscoverer{client: client, providersClient: providersClient}, nil
+}
+
+func (d listDiscoverer) discover(ctx context.Context, rg string) (*discovery, error) {
+	ids, err := listResourceIds(ctx, d.client, rg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Key is the provider namespace (lower cased), value is the API version keyed by the resource type (lower cased)
+	// without the namespace.
+	apiVersions := map[string]map[string]string{}
+	tpl := &armtemplate.Template{}
+	for _, id := range ids {
+		resId, err := armtemplate.ParseResourceId(id)
+		if err != nil {
+			log.Printf("Skipping the listed resource %s: %v\n", id, err)
+			continue
+		}
+		res := armtemplate.Resource{ResourceId: *resId}
+
+		namespace, rt, _ := strings.Cut(resId.Type, "/")
+		versions, ok := apiVersions[strings.ToLower(namespace)]
+		if !ok {
+			versions, err = d.apiVersions(ctx, namespace)
+			if err != nil {
+				return nil, err
+			}
+			apiVersions[strings.ToLower(namespace)] = versions
+		}
+		if version, ok := versions[strings.ToLower(rt)]; ok {
+			resp, err := d.client.GetByID(ctx, id, version, nil)
+			if err != nil {
+				log.Printf("Failed to get the properties of %s: %v\n", id, err)
+			} else {
+				res.Properties = resp.Properties
+			}
+		} else {
+			log.Printf("No API version found for resource type %s\n", resId.Type)
+		}
+		tpl.Resources = append(tpl.Resources, res)
+	}
+	return &discovery{template: tpl}, nil
+}
+
+// listResourceIds lists the ids of the (top level) resources in the resource group.
+func listResourceIds(ctx context.Context, client *armresources.Client, rg string) ([]string, error) {
+	var ids []string
+	pager := client.NewListByResourceGroupPager(rg, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing resources of resource group %s: %w", rg, err)
+		}
+		for _, res := range page.Value {
+			if res.ID != nil {
+				ids = append(ids, *res.ID)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// apiVersions returns the API version (the default one, or the latest stable one) of each resource type of the
+// provider namespace. The key is the lower cased resource type without the namespace.
+func (d listDiscoverer) apiVersions(ctx context.Context, namespace string) (map[string]string, error) {
+	resp, err := d.providersClient.Get(ctx, namespace, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting resource provider %s: %w", namespace, err)
+	}
+	versions := map[string]string{}
+	for _, rt := range resp.ResourceTypes {
+		if rt == nil || rt.ResourceType == nil {
+			continue
+		}
+		if version := pickAPIVersion(rt); version != "" {
+			versions[strings.ToLower(*rt.ResourceType)] = version
+		}
+	}
+	return versions, nil
+}
+
+// pickAPIVersion picks the default API version of the resource type, or the first (i.e. the latest) stable one,
+// or the first one if there is no stable version.
+func pickAPIVersion(rt *armresources.ProviderResourceType) string {
+	if rt.DefaultAPIVersion != nil && *rt.DefaultAPIVersion != "" {
+		return *rt.DefaultAPIVersion
+	}
+	var first string
+	for _, v := range rt.APIVersions {
+		if v == nil {
+			continue
+		}
+		if first == "" {
+			first = *v
+		}
+		if !strings.Contains(strings.ToLower(*v), "preview") {
+			return *v
+		}
+	}
+	return first
+}
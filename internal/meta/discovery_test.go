@@ -0,0 +1,149 @@
+package meta
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Azure/aztfy/internal/armtemplate"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/stretchr/testify/require"
+)
+
+type discoverFunc func(ctx context.Context, rg string) (*discovery, error)
+
+func (f discoverFunc) discover(ctx context.Context, rg string) (*discovery, error) {
+	return f(ctx, rg)
+}
+
+func TestFallbackDiscoverer(t *testing.T) {
+	result := &discovery{
+		template: &armtemplate.Template{
+			Resources: []armtemplate.Resource{
+				{ResourceId: armtemplate.ResourceId{Type: "Microsoft.Network/virtualNetworks", Name: "vnet"}},
+			},
+		},
+	}
+	ok := discoverFunc(func(context.Context, string) (*discovery, error) {
+		return result, nil
+	})
+	fail := discoverFunc(func(_ context.Context, rg string) (*discovery, error) {
+		return nil, fmt.Errorf("failed to discover %s", rg)
+	})
+
+	cases := []struct {
+		name   string
+		input  fallbackDiscoverer
+		expect *discovery
+		err    string
+	}{
+		{
+			name:   "first succeeded",
+			input:  fallbackDiscoverer{ok, fail},
+			expect: result,
+		},
+		{
+			name:   "fallback succeeded",
+			input:  fallbackDiscoverer{fail, ok},
+			expect: result,
+		},
+		{
+			name:  "all failed",
+			input: fallbackDiscoverer{fail, fail},
+			err:   "all discovery strategies failed: failed to discover rg; failed to discover rg",
+		},
+	}
+	for _, c := range cases {
+		actual, err := c.input.discover(context.Background(), "rg")
+		if c.err != "" {
+			require.EqualError(t, err, c.err, c.name)
+			continue
+		}
+		require.NoError(t, err, c.name)
+		require.Equal(t, c.expect, actual, c.name)
+	}
+}
+
+func TestPickAPIVersion(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  armresources.ProviderResourceType
+		expect string
+	}{
+		{
+			name: "default version",
+			input: armresources.ProviderResourceType{
+				APIVersions:       []*string{to.Ptr("2022-01-01"), to.Ptr("2021-01-01")},
+				DefaultAPIVersion: to.Ptr("2021-01-01"),
+			},
+			expect: "2021-01-01",
+		},
+		{
+			name: "latest stable version",
+			input: armresources.ProviderResourceType{
+				APIVersions: []*string{to.Ptr("2022-01-01-preview"), to.Ptr("2021-01-01"), to.Ptr("2020-01-01")},
+			},
+			expect: "2021-01-01",
+		},
+		{
+			name: "preview version only",
+			input: armresources.ProviderResourceType{
+				APIVersions: []*string{to.Ptr("2022-01-01-preview"), to.Ptr("2021-01-01-preview")},
+			},
+			expect: "2022-01-01-preview",
+		},
+		{
+			name:  "no version",
+			input: armresources.ProviderResourceType{},
+		},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.expect, pickAPIVersion(&c.input), c.name)
+	}
+}
+
+func TestAddExportFailures(t *testing.T) {
+	const (
+		vnetId = "/subscriptions/123/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet"
+		siteId = "/subscriptions/123/resourceGroups/rg/providers/Microsoft.Web/sites/site"
+	)
+	tpl := armtemplate.Template{
+		Resources: []armtemplate.Resource{
+			{ResourceId: armtemplate.ResourceId{Type: "Microsoft.Network/virtualNetworks", Name: "vnet"}},
+		},
+	}
+	e := &armresources.ErrorResponse{
+		Code:    to.Ptr("ExportTemplateCompletedWithErrors"),
+		Message: to.Ptr("Export template operation completed with errors."),
+		Details: []*armresources.ErrorResponse{
+			{
+				Code:    to.Ptr("ExportTemplateProviderError"),
+				Target:  to.Ptr(siteId),
+				Message: to.Ptr("Could not get resources of the type 'Microsoft.Web/sites'."),
+			},
+			{
+				Code:   to.Ptr("ResourceExportError"),
+				Target: to.Ptr(strings.Replace(vnetId, "Microsoft.Network", "microsoft.network", 1)),
+			},
+			{
+				Code:   to.Ptr("ResourceGroupExportError"),
+				Target: to.Ptr("/subscriptions/123/resourceGroups/rg"),
+			},
+			{
+				Message: to.Ptr("Some error without target."),
+			},
+		},
+	}
+
+	failures := addExportFailures(&tpl, e)
+	require.Equal(t, map[string]string{
+		strings.ToLower(siteId): "ExportTemplateProviderError: Could not get resources of the type 'Microsoft.Web/sites'.",
+		strings.ToLower(vnetId): "ResourceExportError",
+	}, failures)
+	require.Equal(t, []armtemplate.Resource{
+		{ResourceId: armtemplate.ResourceId{Type: "Microsoft.Network/virtualNetworks", Name: "vnet"}},
+		{ResourceId: armtemplate.ResourceId{Type: "Microsoft.Web/sites", Name: "site"}},
+	}, tpl.Resources)
+}
@@ -0,0 +1,77 @@
+package meta
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/aztfy/internal/armtemplate"
+	"github.com/Azure/aztfy/internal/test/cases"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the recorded cases")
+
+// TestGolden runs the listing, dependency resolution and config generation of the cases offline, from their recorded
+// fixtures, and compares the generated config against the golden files.
+func TestGolden(t *testing.T) {
+	names, err := cases.FixtureNames()
+	require.NoError(t, err)
+	require.NotEmpty(t, names)
+	for _, name := range names {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			fixture, err := cases.LoadFixture(name)
+			require.NoError(t, err)
+			runGoldenCase(t, *fixture)
+		})
+	}
+}
+
+func runGoldenCase(t *testing.T, fixture cases.Fixture) {
+	query := armtemplate.QueryTypeAndId
+	armtemplate.QueryTypeAndId = fixture.QueryTypeAndId
+	defer func() { armtemplate.QueryTypeAndId = query }()
+
+	dir := t.TempDir()
+	tf := newFakeTerraform(dir)
+	tf.providerState = fixture.ProviderState
+	meta := &MetaRgImpl{
+		Meta: Meta{
+			subscriptionId: cases.FixtureSubscriptionId,
+			rootdir:        t.TempDir(),
+			outdir:         dir,
+			tf:             tf,
+			backendType:    "local",
+			empty:          true,
+		},
+		resourceGroup:      cases.FixtureResourceGroupName,
+		resourceNamePrefix: "res-",
+		discoverer:         fileDiscoverer{path: fixture.ArmTemplateFile},
+		offline:            true,
+	}
+	require.NoError(t, meta.initProvider(context.Background()))
+
+	l, err := meta.ListResource()
+	require.NoError(t, err)
+	for i := range l {
+		if l[i].Skip() {
+			continue
+		}
+		meta.Import(&l[i])
+		require.NoError(t, l[i].ImportError, l[i].TFAddr.String())
+	}
+	require.NoError(t, meta.GenerateCfg(l))
+
+	actual, err := os.ReadFile(filepath.Join(dir, "main.tf"))
+	require.NoError(t, err)
+	if *update {
+		require.NoError(t, os.WriteFile(fixture.GoldenFile, actual, 0644))
+		return
+	}
+	expect, err := os.ReadFile(fixture.GoldenFile)
+	require.NoError(t, err, "run with -update to create the golden file")
+	require.Equal(t, string(expect), string(actual))
+}
@@ -0,0 +1,158 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/Azure/aztfy/internal/armtemplate"
+)
+
+const (
+	GraphDOTFileName  = "aztfyGraph.dot"
+	GraphJSONFileName = "aztfyGraph.json"
+)
+
+// Graph is the dependency graph of the resources.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+type GraphNode struct {
+	// The Azure resource id
+	ID string `json:"id"`
+
+	// The ARM resource type
+	ARMType string `json:"arm_type"`
+
+	// The TF resource address, which is empty for the skipped or out of scope resources.
+	TFAddr string `json:"tf_addr,omitempty"`
+
+	// Whether this resource is skipped (i.e. not mapped to any TF resource).
+	Skipped bool `json:"skipped,omitempty"`
+
+	// Whether this resource is outside the export scope (e.g. it resides in another resource group), but is depended on
+	// by some resource inside the scope.
+	OutOfScope bool `json:"out_of_scope,omitempty"`
+}
+
+// GraphEdge indicates the resource "From" depends on the resource "To". Both are Azure resource ids.
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// newGraph builds the dependency graph from the resources of the ARM template, together with the import list for
+// the TF resource addresses.
+func newGraph(resources armtemplate.TFResources, l ImportList) Graph {
+	// The DependsOn of each resource might be either the TF id or the Azure id, map both to the Azure id.
+	toAzureId := map[string]string{}
+	for _, res := range resources {
+		toAzureId[res.TFId] = res.AzureId
+		toAzureId[res.AzureId] = res.AzureId
+	}
+	items := map[string]ImportItem{}
+	for _, item := range l {
+		// The synthetic resources are not part of the ARM template, and might share the id with the resource they derive from.
+		if item.Synthetic {
+			continue
+		}
+		items[item.ResourceID] = item
+	}
+
+	nodes := map[string]GraphNode{}
+	edges := map[GraphEdge]bool{}
+	for _, res := range resources {
+		node := GraphNode{
+			ID:      res.AzureId,
+			ARMType: armtemplate.ArmTypeOfId(res.AzureId),
+			Skipped: true,
+		}
+		if item, ok := items[res.TFId]; ok && !item.Skip() {
+			node.TFAddr = item.TFAddr.String()
+			node.Skipped = false
+		}
+		nodes[node.ID] = node
+
+		for _, dep := range res.DependsOn {
+			depId, ok := toAzureId[dep]
+			if !ok {
+				depId = dep
+				if _, ok := nodes[depId]; !ok {
+					nodes[depId] = GraphNode{
+						ID:         depId,
+						ARMType:    armtemplate.ArmTypeOfId(depId),
+						OutOfScope: true,
+					}
+				}
+			}
+			edges[GraphEdge{From: res.AzureId, To: depId}] = true
+		}
+	}
+
+	g := Graph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	for _, node := range nodes {
+		g.Nodes = append(g.Nodes, node)
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		return g.Nodes[i].ID < g.Nodes[j].ID
+	})
+	for edge := range edges {
+		g.Edges = append(g.Edges, edge)
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+	return g
+}
+
+// DOT returns the graph in the Graphviz DOT language. The skipped resources are dashed, while the out of scope
+// resources are dotted and grayed.
+func (g Graph) DOT() string {
+	buf := bytes.NewBufferString("digraph {\n")
+	for _, node := range g.Nodes {
+		var label string
+		var attrs string
+		switch {
+		case node.OutOfScope:
+			label = node.ARMType + "\n(out of scope)"
+			attrs = `, style="dotted", color="gray", fontcolor="gray"`
+		case node.Skipped:
+			label = node.ARMType + "\n(skipped)"
+			attrs = `, style="dashed"`
+		default:
+			label = node.ARMType + "\n" + node.TFAddr
+		}
+		fmt.Fprintf(buf, "  %s [label=%s, tooltip=%s%s];\n", strconv.Quote(node.ID), strconv.Quote(label), strconv.Quote(node.ID), attrs)
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(buf, "  %s -> %s;\n", strconv.Quote(edge.From), strconv.Quote(edge.To))
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+// writeGraph writes the graph in both DOT and JSON format into the directory.
+func writeGraph(dir string, g Graph) error {
+	b, err := json.MarshalIndent(g, "", "\t")
+	if err != nil {
+		return fmt.Errorf("JSON marshalling the graph: %v", err)
+	}
+	output := filepath.Join(dir, GraphJSONFileName)
+	if err := os.WriteFile(output, b, 0644); err != nil {
+		return fmt.Errorf("writing the graph to %s: %v", output, err)
+	}
+	output = filepath.Join(dir, GraphDOTFileName)
+	if err := os.WriteFile(output, []byte(g.DOT()), 0644); err != nil {
+		return fmt.Errorf("writing the graph to %s: %v", output, err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package meta
+
+import (
+	"testing"
+
+	"github.com/Azure/aztfy/internal/armtemplate"
+	"github.com/Azure/aztfy/internal/tfaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGraph(t *testing.T) {
+	const (
+		vnetId   = "/subscriptions/123/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet"
+		subnetId = "/subscriptions/123/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet"
+		nsgId    = "/subscriptions/123/resourceGroups/rg/providers/Microsoft.Network/networkSecurityGroups/nsg"
+		otherId  = "/subscriptions/123/resourceGroups/other/providers/Microsoft.Network/routeTables/rt"
+	)
+	resources := armtemplate.TFResources{
+		vnetId: {
+			AzureId: vnetId,
+			TFId:    vnetId,
+			TFType:  "azurerm_virtual_network",
+		},
+		subnetId: {
+			AzureId:   subnetId,
+			TFId:      subnetId,
+			TFType:    "azurerm_subnet",
+			DependsOn: []string{vnetId, nsgId, otherId},
+		},
+		nsgId: {
+			AzureId: nsgId,
+			TFId:    nsgId,
+		},
+	}
+	l := ImportList{
+		{ResourceID: vnetId, TFAddr: tfaddr.TFAddr{Type: "azurerm_virtual_network", Name: "res-0"}},
+		{ResourceID: subnetId, TFAddr: tfaddr.TFAddr{Type: "azurerm_subnet", Name: "res-1"}},
+		{ResourceID: nsgId, TFAddr: tfaddr.TFAddr{Name: "res-2"}},
+	}
+
+	g := newGraph(resources, l)
+	require.Equal(t, Graph{
+		Nodes: []GraphNode{
+			{ID: otherId, ARMType: "Microsoft.Network/routeTables", OutOfScope: true},
+			{ID: nsgId, ARMType: "Microsoft.Network/networkSecurityGroups", Skipped: true},
+			{ID: vnetId, ARMType: "Microsoft.Network/virtualNetworks", TFAddr: "azurerm_virtual_network.res-0"},
+			{ID: subnetId, ARMType: "Microsoft.Network/virtualNetworks/subnets", TFAddr: "azurerm_subnet.res-1"},
+		},
+		Edges: []GraphEdge{
+			{From: subnetId, To: otherId},
+			{From: subnetId, To: nsgId},
+			{From: subnetId, To: vnetId},
+		},
+	}, g)
+
+	require.Equal(t, `digraph {
+  "`+otherId+`" [label="Microsoft.Network/routeTables\n(out of scope)", tooltip="`+otherId+`", style="dotted", color="gray", fontcolor="gray"];
+  "`+nsgId+`" [label="Microsoft.Network/networkSecurityGroups\n(skipped)", tooltip="`+nsgId+`", style="dashed"];
+  "`+vnetId+`" [label="Microsoft.Network/virtualNetworks\nazurerm_virtual_network.res-0", tooltip="`+vnetId+`"];
+  "`+subnetId+`" [label="Microsoft.Network/virtualNetworks/subnets\nazurerm_subnet.res-1", tooltip="`+subnetId+`"];
+  "`+subnetId+`" -> "`+otherId+`";
+  "`+subnetId+`" -> "`+nsgId+`";
+  "`+subnetId+`" -> "`+vnetId+`";
+}
+`, g.DOT())
+}
@@ -5,14 +5,31 @@ import (
 	"strings"
 
 	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
 )
 
-func hclBlockAppendDependency(body *hclwrite.Body, ids []string, cfgset map[string]ConfigInfo) error {
+// hclBlockAppendDependency appends the "depends_on" of the ids that the block depends on. For the ids not in the cfgset
+// (i.e. not imported), if there is a data source for it, the hard-coded references to the resource id in the block are
+// replaced by the id of the data source, which is depended on only if there is no such reference. Otherwise, a comment
+// is added.
+func hclBlockAppendDependency(body *hclwrite.Body, ids []string, cfgset map[string]ConfigInfo, dataSources map[string]dataSource) error {
 	dependencies := []string{}
 	for _, id := range ids {
 		cfg, ok := cfgset[id]
 		if !ok {
+			if ds, ok := dataSources[id]; ok {
+				// The block refers to the dependency by its TF id, which might differ from its Azure id.
+				replaced := hclBodyReplaceLiteral(body, id, ds.IdTraversal())
+				if !strings.EqualFold(id, ds.AzureId) && hclBodyReplaceLiteral(body, ds.AzureId, ds.IdTraversal()) {
+					replaced = true
+				}
+				if !replaced {
+					dependencies = append(dependencies, ds.Ref()+",")
+				}
+				continue
+			}
 			dependencies = append(dependencies, fmt.Sprintf("# Depending on %q, which is not imported by Terraform.", id))
 			continue
 		}
@@ -31,27 +48,111 @@ func hclBlockAppendDependency(body *hclwrite.Body, ids []string, cfgset map[stri
 	return nil
 }
 
-func hclBlockAppendLifecycle(body *hclwrite.Body, ignoreChanges []string) error {
-	srcs := map[string][]byte{}
-	if len(ignoreChanges) > 0 {
-		for i := range ignoreChanges {
-			ignoreChanges[i] = ignoreChanges[i] + ","
+// hclBodyReplaceLiteral replaces the string literals that equal to the lit (case insensitively) in the attributes of
+// the body (including the nested blocks) with the traversal. It returns whether any literal is replaced.
+func hclBodyReplaceLiteral(body *hclwrite.Body, lit string, traversal hcl.Traversal) bool {
+	replaced := false
+	for name, attr := range body.Attributes() {
+		tokens := attr.Expr().BuildTokens(nil)
+		var out hclwrite.Tokens
+		found := false
+		for i := 0; i < len(tokens); i++ {
+			if i+2 < len(tokens) &&
+				tokens[i].Type == hclsyntax.TokenOQuote &&
+				tokens[i+1].Type == hclsyntax.TokenQuotedLit &&
+				tokens[i+2].Type == hclsyntax.TokenCQuote &&
+				strings.EqualFold(string(tokens[i+1].Bytes), lit) {
+				out = append(out, hclwrite.TokensForTraversal(traversal)...)
+				i += 2
+				found = true
+				continue
+			}
+			out = append(out, tokens[i])
+		}
+		if found {
+			body.SetAttributeRaw(name, out)
+			replaced = true
+		}
+	}
+	for _, blk := range body.Blocks() {
+		if hclBodyReplaceLiteral(blk.Body(), lit, traversal) {
+			replaced = true
 		}
-		srcs["ignore_changes"] = []byte("ignore_changes = [\n" + strings.Join(ignoreChanges, "\n") + "\n]\n")
 	}
+	return replaced
+}
 
-	if len(srcs) == 0 {
+// hclBlockAppendLifecycle appends the ignoreChanges to the "lifecycle.ignore_changes" of the block.
+// If there is already a "lifecycle" block, the ignoreChanges are merged into it (duplicates are ignored).
+func hclBlockAppendLifecycle(body *hclwrite.Body, ignoreChanges []string) error {
+	if len(ignoreChanges) == 0 {
 		return nil
 	}
 
-	b := hclwrite.NewBlock("lifecycle", nil)
-	for name, src := range srcs {
-		expr, diags := hclwrite.ParseConfig(src, "f", hcl.InitialPos)
-		if diags.HasErrors() {
-			return fmt.Errorf(`building "lifecycle.%s" attribute: %s`, name, diags.Error())
+	b := body.FirstMatchingBlock("lifecycle", nil)
+
+	var items []string
+	if b != nil {
+		if attr := b.Body().GetAttribute("ignore_changes"); attr != nil {
+			existing, err := hclTupleItems(attr.Expr().BuildTokens(nil).Bytes())
+			if err != nil {
+				return fmt.Errorf(`parsing the existing "lifecycle.ignore_changes": %v`, err)
+			}
+			items = existing
+		}
+	}
+	for _, ic := range ignoreChanges {
+		dup := false
+		for _, item := range items {
+			if item == ic {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			items = append(items, ic)
 		}
-		b.Body().SetAttributeRaw(name, expr.Body().GetAttribute(name).Expr().BuildTokens(nil))
 	}
-	body.AppendBlock(b)
+
+	var lines []string
+	for _, item := range items {
+		lines = append(lines, item+",")
+	}
+	src := []byte("ignore_changes = [\n" + strings.Join(lines, "\n") + "\n]\n")
+	expr, diags := hclwrite.ParseConfig(src, "f", hcl.InitialPos)
+	if diags.HasErrors() {
+		return fmt.Errorf(`building "lifecycle.ignore_changes" attribute: %s`, diags.Error())
+	}
+
+	if b == nil {
+		b = body.AppendNewBlock("lifecycle", nil)
+	}
+	b.Body().SetAttributeRaw("ignore_changes", expr.Body().GetAttribute("ignore_changes").Expr().BuildTokens(nil))
 	return nil
 }
+
+// hclBlockSetLifecycleArgument sets the argument of the "lifecycle" block, which will be created if not exists.
+func hclBlockSetLifecycleArgument(body *hclwrite.Body, name string, value cty.Value) {
+	b := body.FirstMatchingBlock("lifecycle", nil)
+	if b == nil {
+		b = body.AppendNewBlock("lifecycle", nil)
+	}
+	b.Body().SetAttributeValue(name, value)
+}
+
+// hclTupleItems returns the source of each item of the tuple expression.
+func hclTupleItems(src []byte) ([]string, error) {
+	expr, diags := hclsyntax.ParseExpression(src, "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf(diags.Error())
+	}
+	tuple, ok := expr.(*hclsyntax.TupleConsExpr)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a tuple", string(src))
+	}
+	var items []string
+	for _, e := range tuple.Exprs {
+		items = append(items, string(e.Range().SliceBytes(src)))
+	}
+	return items, nil
+}
@@ -1,8 +1,11 @@
 package meta
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/Azure/aztfy/internal/tfaddr"
+	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/stretchr/testify/require"
 )
@@ -37,3 +40,117 @@ func TestHclBlockAppendLifecycle(t *testing.T) {
 		require.Equal(t, string(hclwrite.Format(b.BuildTokens(nil).Bytes())), c.expect, c.name)
 	}
 }
+
+func TestHclBlockAppendLifecycle_merge(t *testing.T) {
+	cases := []struct {
+		name          string
+		input         string
+		ignoreChanges []string
+		expect        string
+	}{
+		{
+			name: "merge into existing ignore_changes",
+			input: `lifecycle {
+  ignore_changes = [foo, tags["a"]]
+}
+`,
+			ignoreChanges: []string{"bar", "foo"},
+			expect: `lifecycle {
+  ignore_changes = [
+    foo,
+    tags["a"],
+    bar,
+  ]
+}
+`,
+		},
+		{
+			name: "merge into existing lifecycle",
+			input: `lifecycle {
+  prevent_destroy = true
+}
+`,
+			ignoreChanges: []string{"foo"},
+			expect: `lifecycle {
+  prevent_destroy = true
+  ignore_changes = [
+    foo,
+  ]
+}
+`,
+		},
+	}
+
+	for _, c := range cases {
+		f, diags := hclwrite.ParseConfig([]byte(c.input), "", hcl.InitialPos)
+		require.False(t, diags.HasErrors(), c.name)
+		require.NoError(t, hclBlockAppendLifecycle(f.Body(), c.ignoreChanges), c.name)
+		require.Equal(t, c.expect, string(hclwrite.Format(f.Bytes())), c.name)
+	}
+}
+
+func TestHclBlockAppendDependency_dataSource(t *testing.T) {
+	const vnetId = "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/other/providers/Microsoft.Network/virtualNetworks/vnet"
+	dataSources := map[string]dataSource{
+		vnetId: {
+			AzureId: vnetId,
+			TFAddr:  tfaddr.TFAddr{Type: "azurerm_virtual_network", Name: "ext-0"},
+		},
+	}
+	cases := []struct {
+		name   string
+		input  string
+		ids    []string
+		expect string
+	}{
+		{
+			name: "the reference is replaced",
+			input: `virtual_network_id = "` + vnetId + `"
+`,
+			ids: []string{vnetId},
+			expect: `virtual_network_id = data.azurerm_virtual_network.ext-0.id
+`,
+		},
+		{
+			name: "the nested reference is replaced case insensitively",
+			input: `peering {
+  remote_ids = ["foo", "` + strings.ToUpper(vnetId) + `"]
+}
+`,
+			ids: []string{vnetId},
+			expect: `peering {
+  remote_ids = ["foo", data.azurerm_virtual_network.ext-0.id]
+}
+`,
+		},
+		{
+			name: "the data source is depended on without reference",
+			input: `name = "foo"
+`,
+			ids: []string{vnetId},
+			expect: `name = "foo"
+depends_on = [
+  data.azurerm_virtual_network.ext-0,
+]
+`,
+		},
+		{
+			name: "no data source",
+			input: `name = "foo"
+`,
+			ids: []string{"/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/other"},
+			expect: `name = "foo"
+depends_on = [
+  # Depending on "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/other", which is not imported by Terraform.
+]
+`,
+		},
+	}
+
+	for _, c := range cases {
+		f, diags := hclwrite.ParseConfig([]byte(c.input), "", hcl.InitialPos)
+		require.False(t, diags.HasErrors(), c.name)
+		require.NoError(t, hclBlockAppendDependency(f.Body(), c.ids, nil, dataSources), c.name)
+		require.Equal(t, c.expect, string(hclwrite.Format(f.Bytes())), c.name)
+	}
+}
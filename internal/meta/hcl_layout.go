@@ -0,0 +1,169 @@
+package meta
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/magodo/tfadd/providers/azurerm"
+	"github.com/magodo/tfadd/schema/legacy"
+)
+
+// leadingAttributes are the attributes that always come first (in this order) when they present in a block.
+var leadingAttributes = []string{"name", "resource_group_name", "location"}
+
+// trailingAttributes are the attributes that always come after the nested blocks (but before the meta arguments).
+var trailingAttributes = []string{"tags"}
+
+// metaArgumentAttributes and metaArgumentBlocks are the Terraform meta arguments that always come at the end of a resource block.
+var metaArgumentAttributes = []string{"depends_on"}
+var metaArgumentBlocks = []string{"lifecycle"}
+
+// layoutConfig reorders the content of each resource block, so that the generated configuration has a stable layout
+// regardless of the order that the attributes/blocks are produced (e.g. by "terraform add" or the other transformers).
+// The resulting layout of a resource block is (each section separated by an empty line):
+// - name, resource_group_name, location
+// - other required attributes, alphabetically
+// - optional attributes, alphabetically
+// - nested blocks
+// - tags
+// - meta arguments (depends_on, lifecycle)
+func layoutConfig(configs ConfigInfos) (ConfigInfos, error) {
+	out := make(ConfigInfos, len(configs))
+	for i, cfg := range configs {
+		var sch *legacy.SchemaBlock
+		if rsch, ok := azurerm.ProviderSchemaInfo.ResourceSchemas[cfg.TFAddr.Type]; ok {
+			sch = rsch.Block
+		}
+		for _, blk := range cfg.hcl.Body().Blocks() {
+			hclBlockLayout(blk.Body(), sch, true)
+		}
+		// The attributes are laid out as raw tokens (to keep their comments), parse the result back so that the
+		// structure of the file is intact.
+		f, diags := hclwrite.ParseConfig(cfg.hcl.Bytes(), "", hcl.InitialPos)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("parsing the laid out config of %s: %s", cfg.TFAddr, diags.Error())
+		}
+		cfg.hcl = f
+		out[i] = cfg
+	}
+	return out, nil
+}
+
+// hclBlockLayout reorders the attributes and nested blocks of the body, based on the schema (which can be nil).
+// Nested blocks are reordered recursively. If sectioned is true, each section is separated by an empty line.
+func hclBlockLayout(body *hclwrite.Body, sch *legacy.SchemaBlock, sectioned bool) {
+	attrs := body.Attributes()
+	blocks := body.Blocks()
+	if len(attrs) == 0 && len(blocks) == 0 {
+		return
+	}
+
+	isSpecial := map[string]bool{}
+	for _, l := range [][]string{leadingAttributes, trailingAttributes, metaArgumentAttributes, metaArgumentBlocks} {
+		for _, name := range l {
+			isSpecial[name] = true
+		}
+	}
+
+	pickAttrs := func(names []string) []string {
+		var out []string
+		for _, name := range names {
+			if _, ok := attrs[name]; ok {
+				out = append(out, name)
+			}
+		}
+		return out
+	}
+
+	var required, optional []string
+	for name := range attrs {
+		if isSpecial[name] {
+			continue
+		}
+		if sch != nil {
+			if asch, ok := sch.Attributes[name]; ok && asch.Required {
+				required = append(required, name)
+				continue
+			}
+		}
+		optional = append(optional, name)
+	}
+	sort.Strings(required)
+	sort.Strings(optional)
+
+	var nestedBlocks, metaBlocks []*hclwrite.Block
+	for _, blk := range blocks {
+		isMeta := false
+		for _, name := range metaArgumentBlocks {
+			if blk.Type() == name {
+				isMeta = true
+				break
+			}
+		}
+		if isMeta {
+			metaBlocks = append(metaBlocks, blk)
+			continue
+		}
+		var nsch *legacy.SchemaBlock
+		if sch != nil {
+			if bsch, ok := sch.NestedBlocks[blk.Type()]; ok {
+				nsch = bsch.Block
+			}
+		}
+		hclBlockLayout(blk.Body(), nsch, false)
+		nestedBlocks = append(nestedBlocks, blk)
+	}
+	// Keep the relative order of the blocks of the same type, as it might be significant (e.g. for list blocks).
+	sort.SliceStable(nestedBlocks, func(i, j int) bool {
+		return nestedBlocks[i].Type() < nestedBlocks[j].Type()
+	})
+
+	type section struct {
+		attrs  []string
+		blocks []*hclwrite.Block
+	}
+	sections := []section{
+		{attrs: pickAttrs(leadingAttributes)},
+		{attrs: required},
+		{attrs: optional},
+		{blocks: nestedBlocks},
+		{attrs: pickAttrs(trailingAttributes)},
+		{attrs: pickAttrs(metaArgumentAttributes), blocks: metaBlocks},
+	}
+
+	// The full tokens of the attributes are kept, including their leading and trailing comments.
+	attrTokens := map[string]hclwrite.Tokens{}
+	for name, attr := range attrs {
+		attrTokens[name] = attr.BuildTokens(nil)
+	}
+
+	// Body.Clear() doesn't clean up the attribute/block items, so remove them explicitly before clearing the remaining tokens.
+	for name := range attrs {
+		body.RemoveAttribute(name)
+	}
+	for _, blk := range blocks {
+		body.RemoveBlock(blk)
+	}
+	body.Clear()
+	// The newline right after the opening brace belongs to the body.
+	body.AppendNewline()
+
+	first := true
+	for _, sec := range sections {
+		if len(sec.attrs) == 0 && len(sec.blocks) == 0 {
+			continue
+		}
+		if sectioned && !first {
+			body.AppendNewline()
+		}
+		first = false
+		for _, name := range sec.attrs {
+			body.AppendUnstructuredTokens(attrTokens[name])
+		}
+		for _, blk := range sec.blocks {
+			body.AppendBlock(blk)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package meta
+
+import (
+	"testing"
+
+	"github.com/Azure/aztfy/internal/tfaddr"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/magodo/tfadd/providers/azurerm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHclBlockLayout(t *testing.T) {
+	cases := []struct {
+		name   string
+		rt     string
+		input  string
+		expect string
+	}{
+		{
+			name: "empty block",
+			rt:   "azurerm_resource_group",
+			input: `resource "azurerm_resource_group" "test" {
+}
+`,
+			expect: `resource "azurerm_resource_group" "test" {
+}
+`,
+		},
+		{
+			name: "resource group",
+			rt:   "azurerm_resource_group",
+			input: `resource "azurerm_resource_group" "test" {
+  location = "westeurope"
+  name     = "rg"
+  tags = {
+    foo = "bar"
+  }
+}
+`,
+			expect: `resource "azurerm_resource_group" "test" {
+  name     = "rg"
+  location = "westeurope"
+
+  tags = {
+    foo = "bar"
+  }
+}
+`,
+		},
+		{
+			name: "full sections",
+			rt:   "azurerm_subnet",
+			input: `resource "azurerm_subnet" "test" {
+  address_prefixes                               = ["10.0.2.0/24"]
+  enforce_private_link_endpoint_network_policies = false
+  name                                           = "internal"
+  resource_group_name                            = "rg"
+  virtual_network_name                           = "vnet"
+  delegation {
+    name = "b"
+    service_delegation {
+      name = "Microsoft.Web/serverFarms"
+    }
+  }
+  delegation {
+    name = "a"
+    service_delegation {
+      name = "Microsoft.Web/serverFarms"
+    }
+  }
+  lifecycle {
+    ignore_changes = [foo]
+  }
+  depends_on = [
+    azurerm_virtual_network.test,
+  ]
+}
+`,
+			expect: `resource "azurerm_subnet" "test" {
+  name                = "internal"
+  resource_group_name = "rg"
+
+  address_prefixes     = ["10.0.2.0/24"]
+  virtual_network_name = "vnet"
+
+  enforce_private_link_endpoint_network_policies = false
+
+  delegation {
+    name = "b"
+    service_delegation {
+      name = "Microsoft.Web/serverFarms"
+    }
+  }
+  delegation {
+    name = "a"
+    service_delegation {
+      name = "Microsoft.Web/serverFarms"
+    }
+  }
+
+  depends_on = [
+    azurerm_virtual_network.test,
+  ]
+  lifecycle {
+    ignore_changes = [foo]
+  }
+}
+`,
+		},
+		{
+			name: "comments",
+			rt:   "azurerm_resource_group",
+			input: `resource "azurerm_resource_group" "test" {
+  # keep in sync
+  tags = {
+    foo = "bar" # the owner
+  }
+  location = "westeurope" # managed by team-x
+  # the name
+  name = "rg"
+}
+`,
+			expect: `resource "azurerm_resource_group" "test" {
+  # the name
+  name     = "rg"
+  location = "westeurope" # managed by team-x
+
+  # keep in sync
+  tags = {
+    foo = "bar" # the owner
+  }
+}
+`,
+		},
+		{
+			name: "unknown resource type",
+			rt:   "azurerm_foo",
+			input: `resource "azurerm_foo" "test" {
+  b    = 1
+  a    = 2
+  name = "foo"
+}
+`,
+			expect: `resource "azurerm_foo" "test" {
+  name = "foo"
+
+  a = 2
+  b = 1
+}
+`,
+		},
+	}
+
+	for _, c := range cases {
+		f, diags := hclwrite.ParseConfig([]byte(c.input), "", hcl.InitialPos)
+		require.False(t, diags.HasErrors(), c.name)
+		sch := azurerm.ProviderSchemaInfo.ResourceSchemas[c.rt]
+		if sch == nil {
+			hclBlockLayout(f.Body().Blocks()[0].Body(), nil, true)
+		} else {
+			hclBlockLayout(f.Body().Blocks()[0].Body(), sch.Block, true)
+		}
+		require.Equal(t, c.expect, string(hclwrite.Format(f.Bytes())), c.name)
+	}
+}
+
+func TestLayoutConfig(t *testing.T) {
+	f, diags := hclwrite.ParseConfig([]byte(`resource "azurerm_resource_group" "test" {
+  location = "westeurope" # managed by team-x
+  name     = "rg"
+}
+`), "", hcl.InitialPos)
+	require.False(t, diags.HasErrors())
+	cfgs, err := layoutConfig(ConfigInfos{
+		{
+			ImportItem: ImportItem{TFAddr: tfaddr.TFAddr{Type: "azurerm_resource_group", Name: "test"}},
+			hcl:        f,
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, `resource "azurerm_resource_group" "test" {
+  name     = "rg"
+  location = "westeurope" # managed by team-x
+}
+`, string(hclwrite.Format(cfgs[0].hcl.Bytes())))
+	// The laid out attributes can still be looked up.
+	require.NotNil(t, cfgs[0].hcl.Body().Blocks()[0].Body().GetAttribute("location"))
+}
@@ -0,0 +1,186 @@
+package meta
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Azure/aztfy/internal/tfaddr"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/magodo/tfadd/providers/azurerm"
+)
+
+// IgnoredChange is an attribute that is automatically added to the "lifecycle.ignore_changes" of a resource block.
+type IgnoredChange struct {
+	// The TF resource address
+	TFAddr string
+
+	// The TF resource id
+	ResourceID string
+
+	// The ignored attribute
+	Attribute string
+}
+
+func (c IgnoredChange) String() string {
+	return fmt.Sprintf("%s (%s): %s", c.TFAddr, c.ResourceID, c.Attribute)
+}
+
+// AutoIgnoreChanges runs "terraform plan" on the output workspace, and appends the attributes that still show in-place
+// updates (e.g. write-only or API normalized values) to the "lifecycle.ignore_changes" of the offending resource blocks.
+// This repeats until the plan is clean, no more attribute can be ignored, or the limit of iterations is hit.
+// Only the resource blocks of the import list are modified.
+func (meta Meta) AutoIgnoreChanges(l ImportList, limit int) ([]IgnoredChange, error) {
+	ctx := context.TODO()
+	var ignored []IgnoredChange
+	for i := 0; i < limit; i++ {
+		plan, err := meta.tf.Plan(ctx)
+		if err != nil {
+			return nil, err
+		}
+		candidates := ignoreChangesCandidates(planToResourceDiffs(plan, l))
+		if len(candidates) == 0 {
+			break
+		}
+		added, err := meta.appendIgnoreChanges(candidates)
+		if err != nil {
+			return nil, err
+		}
+		if len(added) == 0 {
+			break
+		}
+		ignored = append(ignored, added...)
+	}
+	return ignored, nil
+}
+
+// ignoreChangesCandidates returns the attributes that can be ignored for each in-place updated resource of the import list,
+// keyed by the TF resource address.
+func ignoreChangesCandidates(diffs []ResourceDiff) map[string]ResourceDiff {
+	out := map[string]ResourceDiff{}
+	for _, diff := range diffs {
+		// Resources that are not in the import list are not generated by us, don't touch them.
+		if diff.ResourceID == "" {
+			continue
+		}
+		if len(diff.Actions) != 1 || diff.Actions[0] != "update" {
+			continue
+		}
+		addr, err := tfaddr.ParseTFResourceAddr(diff.TFAddr)
+		if err != nil {
+			continue
+		}
+		var attrs []string
+		for _, attr := range diff.Attributes {
+			if ignorableAttribute(addr.Type, attr) {
+				attrs = append(attrs, attr)
+			}
+		}
+		if len(attrs) == 0 {
+			continue
+		}
+		diff.Attributes = attrs
+		out[diff.TFAddr] = diff
+	}
+	return out
+}
+
+// ignorableAttribute tells whether the top level attribute (or nested block) of the resource type can be put in the
+// "lifecycle.ignore_changes", i.e. it is not a computed only attribute.
+func ignorableAttribute(rt, name string) bool {
+	if name == "id" {
+		return false
+	}
+	rsch, ok := azurerm.ProviderSchemaInfo.ResourceSchemas[rt]
+	if !ok || rsch.Block == nil {
+		return true
+	}
+	if attr, ok := rsch.Block.Attributes[name]; ok {
+		return attr.Required || attr.Optional
+	}
+	_, ok = rsch.Block.NestedBlocks[name]
+	return ok
+}
+
+// appendIgnoreChanges appends the attributes to the "lifecycle.ignore_changes" of the resource blocks in the main configuration file.
+// It returns the attributes that are newly added.
+func (meta Meta) appendIgnoreChanges(candidates map[string]ResourceDiff) ([]IgnoredChange, error) {
+	cfgFile := filepath.Join(meta.outdir, meta.filenameMainCfg())
+	b, err := os.ReadFile(cfgFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %v", cfgFile, err)
+	}
+	f, diags := hclwrite.ParseConfig(b, cfgFile, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("parsing %s: %s", cfgFile, diags.Error())
+	}
+
+	added, err := hclFileAppendIgnoreChanges(f, candidates)
+	if err != nil {
+		return nil, err
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+	if err := os.WriteFile(cfgFile, hclwrite.Format(f.Bytes()), 0644); err != nil {
+		return nil, fmt.Errorf("writing %s: %v", cfgFile, err)
+	}
+	return added, nil
+}
+
+func hclFileAppendIgnoreChanges(f *hclwrite.File, candidates map[string]ResourceDiff) ([]IgnoredChange, error) {
+	var added []IgnoredChange
+	for _, blk := range f.Body().Blocks() {
+		if blk.Type() != "resource" || len(blk.Labels()) != 2 {
+			continue
+		}
+		addr := blk.Labels()[0] + "." + blk.Labels()[1]
+		diff, ok := candidates[addr]
+		if !ok {
+			continue
+		}
+
+		existing := map[string]bool{}
+		if lc := blk.Body().FirstMatchingBlock("lifecycle", nil); lc != nil {
+			if attr := lc.Body().GetAttribute("ignore_changes"); attr != nil {
+				items, err := hclTupleItems(attr.Expr().BuildTokens(nil).Bytes())
+				if err != nil {
+					return nil, fmt.Errorf(`parsing the existing "lifecycle.ignore_changes" of %s: %v`, addr, err)
+				}
+				for _, item := range items {
+					existing[item] = true
+				}
+			}
+		}
+
+		var attrs []string
+		for _, attr := range diff.Attributes {
+			if !existing[attr] {
+				attrs = append(attrs, attr)
+			}
+		}
+		if len(attrs) == 0 {
+			continue
+		}
+		if err := hclBlockAppendLifecycle(blk.Body(), attrs); err != nil {
+			return nil, fmt.Errorf("appending ignore_changes to %s: %v", addr, err)
+		}
+		for _, attr := range attrs {
+			added = append(added, IgnoredChange{
+				TFAddr:     addr,
+				ResourceID: diff.ResourceID,
+				Attribute:  attr,
+			})
+		}
+	}
+	sort.Slice(added, func(i, j int) bool {
+		if added[i].TFAddr != added[j].TFAddr {
+			return added[i].TFAddr < added[j].TFAddr
+		}
+		return added[i].Attribute < added[j].Attribute
+	})
+	return added, nil
+}
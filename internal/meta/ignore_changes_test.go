@@ -0,0 +1,130 @@
+package meta
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnoreChangesCandidates(t *testing.T) {
+	diffs := []ResourceDiff{
+		{
+			TFAddr:     "azurerm_virtual_network.test",
+			ResourceID: "/subscriptions/123/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet",
+			Actions:    []string{"update"},
+			// "guid" is computed only
+			Attributes: []string{"address_space", "guid", "subnet", "tags"},
+		},
+		{
+			TFAddr:     "azurerm_resource_group.test",
+			ResourceID: "/subscriptions/123/resourceGroups/rg",
+			Actions:    []string{"delete", "create"},
+		},
+		{
+			// Not in the import list
+			TFAddr:     "azurerm_virtual_network.other",
+			Actions:    []string{"update"},
+			Attributes: []string{"tags"},
+		},
+		{
+			TFAddr:     "azurerm_virtual_network.computed",
+			ResourceID: "/subscriptions/123/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet2",
+			Actions:    []string{"update"},
+			Attributes: []string{"guid", "id"},
+		},
+	}
+	require.Equal(t, map[string]ResourceDiff{
+		"azurerm_virtual_network.test": {
+			TFAddr:     "azurerm_virtual_network.test",
+			ResourceID: "/subscriptions/123/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet",
+			Actions:    []string{"update"},
+			Attributes: []string{"address_space", "subnet", "tags"},
+		},
+	}, ignoreChangesCandidates(diffs))
+}
+
+func TestHclFileAppendIgnoreChanges(t *testing.T) {
+	input := `# aztfy:azure_resource_id = /subscriptions/123/resourceGroups/rg1
+resource "azurerm_resource_group" "res-0" {
+  name     = "rg1"
+  location = "westeurope"
+}
+
+resource "azurerm_resource_group" "res-1" {
+  name     = "rg2"
+  location = "westeurope"
+  lifecycle {
+    ignore_changes = [tags]
+  }
+}
+
+resource "azurerm_resource_group" "res-2" {
+  name     = "rg3"
+  location = "westeurope"
+}
+`
+	expect := `# aztfy:azure_resource_id = /subscriptions/123/resourceGroups/rg1
+resource "azurerm_resource_group" "res-0" {
+  name     = "rg1"
+  location = "westeurope"
+  lifecycle {
+    ignore_changes = [
+      tags,
+    ]
+  }
+}
+
+resource "azurerm_resource_group" "res-1" {
+  name     = "rg2"
+  location = "westeurope"
+  lifecycle {
+    ignore_changes = [
+      tags,
+      location,
+    ]
+  }
+}
+
+resource "azurerm_resource_group" "res-2" {
+  name     = "rg3"
+  location = "westeurope"
+}
+`
+	candidates := map[string]ResourceDiff{
+		"azurerm_resource_group.res-0": {
+			TFAddr:     "azurerm_resource_group.res-0",
+			ResourceID: "/subscriptions/123/resourceGroups/rg1",
+			Attributes: []string{"tags"},
+		},
+		"azurerm_resource_group.res-1": {
+			TFAddr:     "azurerm_resource_group.res-1",
+			ResourceID: "/subscriptions/123/resourceGroups/rg2",
+			Attributes: []string{"location", "tags"},
+		},
+	}
+
+	f, diags := hclwrite.ParseConfig([]byte(input), "", hcl.InitialPos)
+	require.False(t, diags.HasErrors())
+	added, err := hclFileAppendIgnoreChanges(f, candidates)
+	require.NoError(t, err)
+	require.Equal(t, []IgnoredChange{
+		{
+			TFAddr:     "azurerm_resource_group.res-0",
+			ResourceID: "/subscriptions/123/resourceGroups/rg1",
+			Attribute:  "tags",
+		},
+		{
+			TFAddr:     "azurerm_resource_group.res-1",
+			ResourceID: "/subscriptions/123/resourceGroups/rg2",
+			Attribute:  "location",
+		},
+	}, added)
+	require.Equal(t, expect, string(hclwrite.Format(f.Bytes())))
+
+	// Appending the same attributes again adds nothing.
+	added, err = hclFileAppendIgnoreChanges(f, candidates)
+	require.NoError(t, err)
+	require.Empty(t, added)
+}
@@ -8,6 +8,13 @@ type ImportItem struct {
 	// The TF resource id
 	ResourceID string
 
+	// The Azure resource id, which might be different than the TF resource id (e.g. for data plane only resources)
+	AzureResourceID string
+
+	// The error of exporting this azure resource into the ARM template, if any. Such resource is skipped by default, as
+	// its properties and dependencies are unknown.
+	ExportError error
+
 	// Whether this azure resource failed to import into terraform (this might due to the TFResourceType doesn't match the resource)
 	ImportError error
 
@@ -17,6 +24,17 @@ type ImportItem struct {
 	// Whether this azure resource failed to validate into terraform (tbh, this should reside in UI layer only)
 	ValidateError error
 
+	// The reason why this azure resource is blocked from importing (e.g. its dependency failed to import), if any
+	BlockedReason string
+
+	// The TF resource ids of the resources (in the import list) that this resource depends on
+	DependsOn []string
+
+	// Whether this is a synthetic resource (e.g. an association resource), which doesn't exist in Azure as a standalone
+	// resource, but is derived from the properties of the resources it depends on. Its TF resource id might be the same
+	// as one of them (e.g. the subnet network security group association shares the subnet's id).
+	Synthetic bool
+
 	// The terraform resource
 	TFAddr tfaddr.TFAddr
 
@@ -63,3 +81,22 @@ func (l ImportList) Imported() ImportList {
 	}
 	return out
 }
+
+// FailedDependency returns the dependency of the item, which either failed to import or is blocked, if any.
+// As the import list is in dependency order, this will also catch the transitive dependencies that failed, as long as
+// the dependencies are processed before their dependents.
+func (l ImportList) FailedDependency(item ImportItem) (ImportItem, bool) {
+	deps := map[string]bool{}
+	for _, dep := range item.DependsOn {
+		deps[dep] = true
+	}
+	for _, dep := range l {
+		if !deps[dep.ResourceID] || dep.Skip() || dep.Synthetic {
+			continue
+		}
+		if dep.ImportError != nil || dep.BlockedReason != "" {
+			return dep, true
+		}
+	}
+	return ImportItem{}, false
+}
@@ -0,0 +1,53 @@
+package meta
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Azure/aztfy/internal/tfaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportListFailedDependency(t *testing.T) {
+	l := ImportList{
+		{ResourceID: "rg", TFAddr: tfaddr.TFAddr{Type: "azurerm_resource_group", Name: "res-0"}, Imported: true},
+		{ResourceID: "skipped", DependsOn: []string{"rg"}},
+		{ResourceID: "vnet", TFAddr: tfaddr.TFAddr{Type: "azurerm_virtual_network", Name: "res-1"}, DependsOn: []string{"rg"}, ImportError: fmt.Errorf("failed")},
+		{ResourceID: "subnet", TFAddr: tfaddr.TFAddr{Type: "azurerm_subnet", Name: "res-2"}, DependsOn: []string{"rg", "vnet"}, BlockedReason: "blocked"},
+		{ResourceID: "nsg", TFAddr: tfaddr.TFAddr{Type: "azurerm_network_security_group", Name: "res-3"}, Imported: true},
+		{ResourceID: "nsg", TFAddr: tfaddr.TFAddr{Type: "azurerm_subnet_network_security_group_association", Name: "res-4"}, DependsOn: []string{"nsg"}, Synthetic: true, ImportError: fmt.Errorf("failed")},
+	}
+	cases := []struct {
+		name   string
+		item   ImportItem
+		expect string
+	}{
+		{
+			name: "dependency imported",
+			item: ImportItem{DependsOn: []string{"rg"}},
+		},
+		{
+			name: "dependency skipped",
+			item: ImportItem{DependsOn: []string{"skipped"}},
+		},
+		{
+			name:   "dependency failed",
+			item:   ImportItem{DependsOn: []string{"rg", "vnet"}},
+			expect: "vnet",
+		},
+		{
+			name:   "dependency blocked",
+			item:   ImportItem{DependsOn: []string{"subnet"}},
+			expect: "subnet",
+		},
+		{
+			name: "synthetic resource sharing the id failed",
+			item: ImportItem{DependsOn: []string{"nsg"}},
+		},
+	}
+	for _, c := range cases {
+		dep, ok := l.FailedDependency(c.item)
+		require.Equal(t, c.expect != "", ok, c.name)
+		require.Equal(t, c.expect, dep.ResourceID, c.name)
+	}
+}
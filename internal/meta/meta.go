@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/Azure/aztfy/internal/client"
 	"github.com/Azure/aztfy/internal/config"
@@ -17,7 +18,6 @@ import (
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/hashicorp/terraform-exec/tfexec"
 	"github.com/magodo/tfadd/providers/azurerm"
-	"github.com/magodo/tfadd/tfadd"
 )
 
 type TFConfigTransformer func(configs ConfigInfos) (ConfigInfos, error)
@@ -28,6 +28,9 @@ type meta interface {
 	Import(item *ImportItem)
 	CleanTFState(addr string)
 	GenerateCfg(ImportList) error
+	GenerateDataSourceCfg(ImportList) error
+	Verify(ImportList) ([]ResourceDiff, error)
+	AutoIgnoreChanges(ImportList, int) ([]IgnoredChange, error)
 }
 
 var _ meta = &Meta{}
@@ -36,18 +39,33 @@ type Meta struct {
 	subscriptionId string
 	rootdir        string
 	outdir         string
-	tf             *tfexec.Terraform
+	tf             terraformExecutor
 	clientBuilder  *client.ClientBuilder
 	devProvider    bool
 	backendType    string
 	backendConfig  []string
+	// The cloud environment, which is propagated to the provider block.
+	environment client.Environment
 	// Use a safer name which is less likely to conflicts with users' existing files.
 	// This is mainly used for the --append option.
 	useSafeFilename bool
 	empty           bool
+	// Whether to add provenance annotations on each generated resource block.
+	annotate     bool
+	aztfyVersion string
+	// The declarative rewrite rules, which are applied after the builtin transformers.
+	rewriteRules RewriteRules
+	// The paths to the external transformers, which run in order after the builtin ones.
+	transformers []string
 }
 
 func NewMeta(cfg config.CommonConfig) (*Meta, error) {
+	return newMeta(cfg, false)
+}
+
+// newMeta builds the Meta. In the offline mode, aztfy itself doesn't talk to Azure (e.g. reading the resources from an
+// ARM template file), so no credential is built, while the provider is still set up to authenticate via cfg.Auth.
+func newMeta(cfg config.CommonConfig, offline bool) (*Meta, error) {
 	// Initialize the rootdir
 	cachedir, err := os.UserCacheDir()
 	if err != nil {
@@ -113,9 +131,23 @@ The output directory is not empty. Please choose one of actions below:
 	}
 
 	// Construct client builder
-	b, err := client.NewClientBuilder()
-	if err != nil {
-		return nil, fmt.Errorf("building authorizer: %w", err)
+	var b *client.ClientBuilder
+	var env client.Environment
+	if offline {
+		if err := client.SetupProviderAuth(cfg.Auth); err != nil {
+			return nil, fmt.Errorf("setting up the provider authentication: %w", err)
+		}
+		e, err := client.EnvironmentFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		env = *e
+	} else {
+		b, err = client.NewClientBuilder(cfg.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("building authorizer: %w", err)
+		}
+		env = b.Environment()
 	}
 
 	// AzureRM provider will honor env.var "AZURE_HTTP_USER_AGENT" when constructing for HTTP "User-Agent" header.
@@ -125,16 +157,29 @@ The output directory is not empty. Please choose one of actions below:
 	os.Setenv("ARM_PROVIDER_ENHANCED_VALIDATION", "false")
 	os.Setenv("ARM_SKIP_PROVIDER_REGISTRATION", "true")
 
+	var rules RewriteRules
+	if cfg.RewriteRulesFile != "" {
+		rules, err = LoadRewriteRules(cfg.RewriteRulesFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	meta := &Meta{
 		subscriptionId:  cfg.SubscriptionId,
 		rootdir:         rootdir,
 		outdir:          outdir,
 		clientBuilder:   b,
+		environment:     env,
 		devProvider:     cfg.DevProvider,
 		backendType:     cfg.BackendType,
 		backendConfig:   cfg.BackendConfig,
 		useSafeFilename: cfg.Append,
 		empty:           empty,
+		annotate:        cfg.Annotate,
+		aztfyVersion:    cfg.AztfyVersion,
+		rewriteRules:    rules,
+		transformers:    cfg.Transformers,
 	}
 
 	return meta, nil
@@ -166,7 +211,7 @@ func (meta *Meta) Init() error {
 	if v, ok := os.LookupEnv("TF_LOG"); ok {
 		tf.SetLog(v)
 	}
-	meta.tf = tf
+	meta.tf = tfexecExecutor{tf: tf, tmpdir: meta.rootdir}
 
 	// Initialize the provider
 	if err := meta.initProvider(ctx); err != nil {
@@ -210,11 +255,23 @@ func (meta Meta) generateCfg(l ImportList, cfgTrans ...TFConfigTransformer) erro
 	if err != nil {
 		return fmt.Errorf("converting from state to configurations: %w", err)
 	}
+	if len(meta.rewriteRules) != 0 {
+		cfgTrans = append(cfgTrans, rewriteRulesTransformer(meta.rewriteRules))
+	}
+	for _, path := range meta.transformers {
+		cfgTrans = append(cfgTrans, externalTransformer(path))
+	}
 	cfginfos, err = meta.terraformMetaHook(cfginfos, cfgTrans...)
 	if err != nil {
 		return fmt.Errorf("Terraform HCL meta hook: %w", err)
 	}
 
+	// Layout the configurations at last, as the meta hooks above might append new attributes/blocks.
+	cfginfos, err = layoutConfig(cfginfos)
+	if err != nil {
+		return fmt.Errorf("laying out the configurations: %w", err)
+	}
+
 	return meta.generateConfig(cfginfos)
 }
 
@@ -224,10 +281,7 @@ func (meta *Meta) providerConfig() string {
   backend %q {}
 }
 
-provider "azurerm" {
-  features {}
-}
-`, meta.backendType)
+%s`, meta.backendType, meta.providerBlock())
 	}
 
 	return fmt.Sprintf(`terraform {
@@ -240,10 +294,32 @@ provider "azurerm" {
   }
 }
 
-provider "azurerm" {
-  features {}
+%s`, meta.backendType, azurerm.ProviderSchemaInfo.Version, meta.providerBlock())
+}
+
+// providerBlock returns the "azurerm" provider block, which talks to the same cloud environment as aztfy.
+func (meta Meta) providerBlock() string {
+	var attrs string
+	if name := meta.environment.Name; name != "" && name != "public" {
+		attrs += fmt.Sprintf("environment = %q\n", name)
+	}
+	if host := meta.environment.MetadataHost; host != "" {
+		attrs += fmt.Sprintf("metadata_host = %q\n", host)
+	}
+	if attrs != "" {
+		attrs += "\n"
+	}
+	return string(hclwrite.Format([]byte(fmt.Sprintf(`provider "azurerm" {
+%s  features {}
+}
+`, attrs))))
 }
-`, meta.backendType, azurerm.ProviderSchemaInfo.Version)
+
+func (meta Meta) providerVersion() string {
+	if meta.devProvider {
+		return "dev"
+	}
+	return azurerm.ProviderSchemaInfo.Version
 }
 
 func (meta Meta) filenameProviderSetting() string {
@@ -275,11 +351,7 @@ func (meta *Meta) initProvider(ctx context.Context) error {
 			return fmt.Errorf("error creating provider config: %w", err)
 		}
 
-		var opts []tfexec.InitOption
-		for _, opt := range meta.backendConfig {
-			opts = append(opts, tfexec.BackendConfig(opt))
-		}
-		if err := meta.tf.Init(ctx, opts...); err != nil {
+		if err := meta.tf.Init(ctx, meta.backendConfig); err != nil {
 			return fmt.Errorf("error running terraform init: %s", err)
 		}
 		return nil
@@ -290,10 +362,7 @@ func (meta *Meta) initProvider(ctx context.Context) error {
 		return err
 	}
 	if !exists {
-		if err := appendToFile(meta.filenameProviderSetting(), `provider "azurerm" {
-  features {}
-}
-`); err != nil {
+		if err := appendToFile(filepath.Join(meta.outdir, meta.filenameProviderSetting()), meta.providerBlock()); err != nil {
 			return fmt.Errorf("error creating provider config: %w", err)
 		}
 	}
@@ -304,7 +373,7 @@ func (meta *Meta) initProvider(ctx context.Context) error {
 func (meta Meta) stateToConfig(ctx context.Context, list ImportList) (ConfigInfos, error) {
 	out := ConfigInfos{}
 	for _, item := range list.Imported() {
-		b, err := tfadd.State(ctx, meta.tf, tfadd.Target(item.TFAddr.String()))
+		b, err := meta.tf.Add(ctx, item.TFAddr.String())
 		if err != nil {
 			return nil, fmt.Errorf("converting terraform state to config for resource %s: %w", item.TFAddr, err)
 		}
@@ -337,7 +406,14 @@ func (meta Meta) terraformMetaHook(configs ConfigInfos, cfgTrans ...TFConfigTran
 func (meta Meta) generateConfig(cfgs ConfigInfos) error {
 	cfgFile := filepath.Join(meta.outdir, meta.filenameMainCfg())
 	buf := bytes.NewBuffer([]byte{})
+	timestamp := time.Now().UTC().Format(time.RFC3339)
 	for _, cfg := range cfgs {
+		// The synthetic resources are not annotated, as they might share the TF resource id with the resource they
+		// derive from, which would make the resource mapping rebuilt from the annotations ambiguous. Neither are the data
+		// sources, which are not imported.
+		if meta.annotate && !cfg.Synthetic && !cfg.DataSource {
+			buf.WriteString(newAnnotation(cfg.ImportItem, meta.aztfyVersion, meta.providerVersion(), timestamp).String())
+		}
 		if _, err := cfg.DumpHCL(buf); err != nil {
 			return err
 		}
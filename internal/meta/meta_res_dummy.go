@@ -0,0 +1,47 @@
+package meta
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Azure/aztfy/internal/config"
+)
+
+var _ ResMeta = &MetaResDummy{}
+
+type MetaResDummy struct {
+	scenarioMeta
+	id string
+}
+
+func newResMetaDummy(cfg config.ResConfig) (ResMeta, error) {
+	scenario, err := newScenario(cfg.MockScenarioFile)
+	if err != nil {
+		return nil, err
+	}
+	return MetaResDummy{scenarioMeta: scenarioMeta{scenario: *scenario}, id: cfg.ResourceId}, nil
+}
+
+func (m MetaResDummy) ResourceId() string {
+	return m.id
+}
+
+// QueryResourceTypeAndId returns the type (or the first recommendation) of the resource in the scenario.
+func (m MetaResDummy) QueryResourceTypeAndId() (string, string, error) {
+	time.Sleep(m.scenario.Latency)
+	res, ok := m.scenario.resource(m.id)
+	if !ok {
+		return "", "", fmt.Errorf("resource %s is not found in the scenario", m.id)
+	}
+	if err := scenarioError(res.ExportError); err != nil {
+		return "", "", err
+	}
+	rt := res.Type
+	if rt == "" && len(res.Recommendations) != 0 {
+		rt = res.Recommendations[0]
+	}
+	if rt == "" {
+		return "", "", fmt.Errorf("resource %s has neither type nor recommendation in the scenario", m.id)
+	}
+	return rt, res.Id, nil
+}
@@ -0,0 +1,47 @@
+package meta
+
+import (
+	"fmt"
+
+	"github.com/Azure/aztfy/internal/config"
+	"github.com/magodo/aztft/aztft"
+)
+
+var _ ResMeta = &MetaResImpl{}
+
+type MetaResImpl struct {
+	Meta
+	Id           string
+	ResourceName string
+}
+
+func newResMetaRes(cfg config.ResConfig) (ResMeta, error) {
+	baseMeta, err := NewMeta(cfg.CommonConfig)
+	if err != nil {
+		return nil, err
+	}
+	meta := &MetaResImpl{
+		Meta:         *baseMeta,
+		Id:           cfg.ResourceId,
+		ResourceName: cfg.ResourceName,
+	}
+	return meta, nil
+}
+
+func (meta MetaResImpl) ResourceId() string {
+	return meta.Id
+}
+
+func (meta MetaResImpl) QueryResourceTypeAndId() (string, string, error) {
+	lrt, lid, err := aztft.QueryTypeAndId(meta.Id, true)
+	if err != nil {
+		return "", "", err
+	}
+	if len(lrt) != 1 {
+		return "", "", fmt.Errorf("expect exactly one resource type, got=%d", len(lrt))
+	}
+	if len(lid) != 1 {
+		return "", "", fmt.Errorf("expect exactly one resource id, got=%d", len(lid))
+	}
+	return lrt[0], lid[0], nil
+}
@@ -4,18 +4,27 @@ import (
 	"github.com/Azure/aztfy/internal/config"
 )
 
-const ResourceMappingFileName = ".aztfyResourceMapping.json"
+const (
+	ResourceMappingFileName = ".aztfyResourceMapping.json"
+
+	// The ARM template as exported (or listed), which can be used via the `--arm-template` option.
+	ArmTemplateFileName = "aztfyArmTemplate.json"
+	// The ARM template after being tweaked (e.g. the managed resources are populated), which is for inspection only.
+	TweakedArmTemplateFileName = "aztfyArmTemplate.tweaked.json"
+)
 
 type RgMeta interface {
 	meta
 	ResourceGroupName() string
 	ListResource() (ImportList, error)
 	ExportResourceMapping(l ImportList) error
+	ExportGraph(l ImportList) error
+	ExportArmTemplate() error
 }
 
 func NewRgMeta(cfg config.RgConfig) (RgMeta, error) {
 	if cfg.MockClient {
-		return newRgMetaDummy(cfg.ResourceGroupName)
+		return newRgMetaDummy(cfg.ResourceGroupName, cfg.MockScenarioFile)
 	}
 	return newRgMetaRg(cfg)
 }
@@ -1,68 +1,73 @@
 package meta
 
 import (
+	"fmt"
+	"strings"
 	"time"
+
+	"github.com/Azure/aztfy/internal/tfaddr"
 )
 
 var _ RgMeta = &MetaRgDummy{}
 
 type MetaRgDummy struct {
+	scenarioMeta
 	rg string
 }
 
-func newRgMetaDummy(rg string) (RgMeta, error) {
-	return MetaRgDummy{rg: rg}, nil
-}
-
-func (m MetaRgDummy) Init() error {
-	time.Sleep(500 * time.Millisecond)
-	return nil
+func newRgMetaDummy(rg, scenarioFile string) (RgMeta, error) {
+	scenario, err := newScenario(scenarioFile)
+	if err != nil {
+		return nil, err
+	}
+	return MetaRgDummy{scenarioMeta: scenarioMeta{scenario: *scenario}, rg: rg}, nil
 }
 
 func (m MetaRgDummy) ResourceGroupName() string {
 	return m.rg
 }
 
-func (m MetaRgDummy) Workspace() string {
-	return "example-workspace"
-}
-
 func (m MetaRgDummy) ListResource() (ImportList, error) {
-	time.Sleep(500 * time.Millisecond)
-	return ImportList{
-		ImportItem{
-			ResourceID: "/subscriptions/0000000-0000-0000-0000-00000000000/resourceGroups/example-rg/providers/Microsoft.Network/virtualNetworks/example-network",
-		},
-		ImportItem{
-			ResourceID: "/subscriptions/0000000-0000-0000-0000-00000000000/resourceGroups/example-rg/providers/Microsoft.Compute/virtualMachines/example-machine",
-		},
-		ImportItem{
-			ResourceID: "/subscriptions/0000000-0000-0000-0000-00000000000/resourceGroups/example-rg/providers/Microsoft.Network/networkInterfaces/example-nic",
-		},
-		ImportItem{
-			ResourceID: "/subscriptions/0000000-0000-0000-0000-00000000000/resourceGroups/example-rg/providers/Microsoft.Network/virtualNetworks/example-network/subnets/internal",
-		},
-		ImportItem{
-			ResourceID: "/subscriptions/0000000-0000-0000-0000-00000000000/resourceGroups/example-rg",
-		},
-	}, nil
+	time.Sleep(m.scenario.Latency)
+	if err := scenarioError(m.scenario.ListError); err != nil {
+		return nil, err
+	}
+	var l ImportList
+	for i, res := range m.scenario.Resources {
+		item := ImportItem{
+			ResourceID:      res.Id,
+			AzureResourceID: res.Id,
+			DependsOn:       res.DependsOn,
+			TFAddr: tfaddr.TFAddr{
+				Name: fmt.Sprintf("res-%d", i),
+			},
+			Recommendations: res.Recommendations,
+			ExportError:     scenarioError(res.ExportError),
+		}
+		if item.ExportError == nil && res.Type != "" {
+			item.TFAddr.Type = res.Type
+			for _, rec := range res.Recommendations {
+				if strings.EqualFold(rec, res.Type) {
+					item.IsRecommended = true
+				}
+			}
+		}
+		l = append(l, item)
+	}
+	return l, nil
 }
 
-func (m MetaRgDummy) CleanTFState(_ string) {
-	return
-}
-
-func (m MetaRgDummy) Import(item *ImportItem) {
-	time.Sleep(time.Second)
-	return
+func (m MetaRgDummy) ExportResourceMapping(l ImportList) error {
+	time.Sleep(m.scenario.Latency)
+	return nil
 }
 
-func (m MetaRgDummy) GenerateCfg(l ImportList) error {
-	time.Sleep(500 * time.Millisecond)
+func (m MetaRgDummy) ExportGraph(l ImportList) error {
+	time.Sleep(m.scenario.Latency)
 	return nil
 }
 
-func (m MetaRgDummy) ExportResourceMapping(l ImportList) error {
-	time.Sleep(500 * time.Millisecond)
+func (m MetaRgDummy) ExportArmTemplate() error {
+	time.Sleep(m.scenario.Latency)
 	return nil
 }
@@ -4,19 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
-	"time"
 
 	"github.com/Azure/aztfy/internal/armtemplate"
 	"github.com/Azure/aztfy/internal/resmap"
 	"github.com/Azure/aztfy/internal/tfaddr"
 
 	"github.com/Azure/aztfy/internal/config"
-	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
-	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
 )
 
 var _ RgMeta = &MetaRgImpl{}
@@ -32,18 +30,56 @@ type MetaRgImpl struct {
 
 	resourceNamePrefix string
 	resourceNameSuffix string
+
+	// The table of the managed resources to be populated into the ARM template.
+	managedResources armtemplate.ManagedResourceTable
+
+	// The discoverer of the resources in the resource group.
+	discoverer discoverer
+
+	// Whether to generate data sources for the resources that are depended on, but not imported.
+	externalDataSources bool
+
+	// Whether the resources are read from an ARM template file, in which case no Azure API is called for discovery.
+	offline bool
+
+	// The raw ARM template, and the one after being tweaked, of the last discovery.
+	rawTemplate     []byte
+	tweakedTemplate *armtemplate.Template
+
+	// The export errors of the resources that failed to be exported into the ARM template, keyed by the lower cased
+	// Azure resource id.
+	exportErrors map[string]string
 }
 
 func newRgMetaRg(cfg config.RgConfig) (RgMeta, error) {
-	baseMeta, err := NewMeta(cfg.CommonConfig)
+	baseMeta, err := newMeta(cfg.CommonConfig, cfg.ArmTemplateFile != "")
 	if err != nil {
 		return nil, err
 	}
 
 	meta := &MetaRgImpl{
-		Meta:            *baseMeta,
-		resourceGroup:   cfg.ResourceGroupName,
-		resourceMapping: cfg.ResourceMapping,
+		Meta:                *baseMeta,
+		resourceGroup:       cfg.ResourceGroupName,
+		resourceMapping:     cfg.ResourceMapping,
+		externalDataSources: cfg.ExternalDataSources,
+	}
+
+	if cfg.ArmTemplateFile != "" {
+		meta.discoverer = fileDiscoverer{path: cfg.ArmTemplateFile}
+		meta.offline = true
+	} else {
+		meta.discoverer, err = newDiscoverer(cfg.Discovery, cfg.ExportChunkSize, meta.Meta.clientBuilder, meta.subscriptionId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.ManagedResourcesFile != "" {
+		meta.managedResources, err = armtemplate.LoadManagedResourceTable(cfg.ManagedResourcesFile)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if pos := strings.LastIndex(cfg.ResourceNamePattern, "*"); pos != -1 {
@@ -62,7 +98,7 @@ func (meta MetaRgImpl) ResourceGroupName() string {
 func (meta *MetaRgImpl) ListResource() (ImportList, error) {
 	ctx := context.TODO()
 
-	if err := meta.exportArmTemplate(ctx); err != nil {
+	if err := meta.discoverResources(ctx); err != nil {
 		return nil, err
 	}
 
@@ -76,31 +112,100 @@ func (meta *MetaRgImpl) ListResource() (ImportList, error) {
 		return rl[i].AzureId < rl[j].AzureId
 	})
 
+	// Order the resources by dependency, so that the dependencies are imported before their dependents. While the
+	// resources are still named in the order of their Azure ids, to keep the names stable.
+	var ids []string
+	nameIndexes := map[string]int{}
 	for i, res := range rl {
+		ids = append(ids, res.TFId)
+		nameIndexes[res.TFId] = i
+	}
+	ids = meta.resources.SortByDependency(ids)
+
+	for _, id := range ids {
+		res := meta.resources[id]
 		item := ImportItem{
-			ResourceID: res.TFId,
+			ResourceID:      res.TFId,
+			AzureResourceID: res.AzureId,
 			TFAddr: tfaddr.TFAddr{
 				Type: "",
-				Name: fmt.Sprintf("%s%d%s", meta.resourceNamePrefix, i, meta.resourceNameSuffix),
+				Name: fmt.Sprintf("%s%d%s", meta.resourceNamePrefix, nameIndexes[id], meta.resourceNameSuffix),
 			},
 		}
+		for _, dep := range res.DependsOn {
+			if _, ok := meta.resources[dep]; ok {
+				item.DependsOn = append(item.DependsOn, dep)
+			}
+		}
 		if res.TFType != "" {
 			item.Recommendations = []string{res.TFType}
 		}
 
+		if msg, ok := meta.exportErrors[strings.ToLower(res.AzureId)]; ok {
+			item.ExportError = fmt.Errorf("%s", msg)
+		}
+
 		if len(meta.resourceMapping) != 0 {
 			if addr, ok := meta.resourceMapping[res.TFId]; ok {
 				item.TFAddr = addr
 			}
 		} else {
 			// Only auto deduce the TF resource type from recommendations when there is no resource mapping file specified.
-			if res.TFType != "" {
+			// The resources that failed to be exported are skipped, unless they are explicitly mapped.
+			if res.TFType != "" && item.ExportError == nil {
 				item.TFAddr.Type = res.TFType
 				item.IsRecommended = true
 			}
 		}
 		l = append(l, item)
 	}
+
+	// Append the association resources, which are only selected when none of the resources they link is skipped.
+	assocs, err := meta.resources.Associations(meta.subscriptionId, meta.resourceGroup)
+	if err != nil {
+		return nil, fmt.Errorf("detecting association resources: %v", err)
+	}
+	items := map[string]ImportItem{}
+	// The names of the association resources are generated after all the other names are settled (e.g. via the
+	// resource mapping), skipping the ones already taken.
+	usedNames := map[string]bool{}
+	for _, item := range l {
+		items[item.ResourceID] = item
+		usedNames[item.TFAddr.Name] = true
+	}
+	nameIndex := len(l)
+	for _, assoc := range assocs {
+		name := fmt.Sprintf("%s%d%s", meta.resourceNamePrefix, nameIndex, meta.resourceNameSuffix)
+		for usedNames[name] {
+			nameIndex++
+			name = fmt.Sprintf("%s%d%s", meta.resourceNamePrefix, nameIndex, meta.resourceNameSuffix)
+		}
+		usedNames[name] = true
+		nameIndex++
+
+		item := ImportItem{
+			ResourceID: assoc.TFId,
+			Synthetic:  true,
+			TFAddr: tfaddr.TFAddr{
+				Type: assoc.TFType,
+				Name: name,
+			},
+			IsRecommended:   true,
+			Recommendations: []string{assoc.TFType},
+		}
+		for _, dep := range assoc.DependsOn {
+			depItem, ok := items[dep]
+			if !ok {
+				continue
+			}
+			item.DependsOn = append(item.DependsOn, dep)
+			if depItem.Skip() {
+				item.TFAddr.Type = ""
+				item.IsRecommended = false
+			}
+		}
+		l = append(l, item)
+	}
 	return l, nil
 }
 
@@ -111,6 +216,10 @@ func (meta MetaRgImpl) GenerateCfg(l ImportList) error {
 func (meta MetaRgImpl) ExportResourceMapping(l ImportList) error {
 	m := resmap.ResourceMapping{}
 	for _, item := range l {
+		// The synthetic resources are always derived from the resources they depend on.
+		if item.Synthetic {
+			continue
+		}
 		m[item.ResourceID] = item.TFAddr
 	}
 	output := filepath.Join(meta.Workspace(), ResourceMappingFileName)
@@ -124,54 +233,131 @@ func (meta MetaRgImpl) ExportResourceMapping(l ImportList) error {
 	return nil
 }
 
-func (meta *MetaRgImpl) exportArmTemplate(ctx context.Context) error {
-	client, err := meta.Meta.clientBuilder.NewResourceGroupClient(meta.subscriptionId)
+// ExportGraph writes the dependency graph of the resources into the workspace, in both DOT and JSON format.
+func (meta MetaRgImpl) ExportGraph(l ImportList) error {
+	return writeGraph(meta.Workspace(), newGraph(meta.resources, l))
+}
+
+// ExportArmTemplate discovers the resources, then writes both the raw and the tweaked ARM template into the workspace,
+// together with the export errors of the resources that failed to be exported, if any.
+func (meta *MetaRgImpl) ExportArmTemplate() error {
+	if err := meta.discoverResources(context.TODO()); err != nil {
+		return err
+	}
+	tweaked, err := json.MarshalIndent(meta.tweakedTemplate, "", "\t")
 	if err != nil {
-		return fmt.Errorf("building resource group client: %v", err)
+		return fmt.Errorf("JSON marshalling the tweaked arm template: %v", err)
+	}
+	for name, b := range map[string][]byte{
+		ArmTemplateFileName:        meta.rawTemplate,
+		TweakedArmTemplateFileName: tweaked,
+	} {
+		output := filepath.Join(meta.Workspace(), name)
+		if err := os.WriteFile(output, b, 0644); err != nil {
+			return fmt.Errorf("writing the arm template to %s: %v", output, err)
+		}
 	}
+	if len(meta.exportErrors) == 0 {
+		return nil
+	}
+	// The export errors are keyed by the resource ids in their original case, so that the failed resources can be
+	// added back to the template when it is read from the file.
+	exportErrors := map[string]string{}
+	for _, res := range meta.tweakedTemplate.Resources {
+		id := res.ResourceId.ID(meta.subscriptionId, meta.resourceGroup)
+		if msg, ok := meta.exportErrors[strings.ToLower(id)]; ok {
+			exportErrors[id] = msg
+		}
+	}
+	b, err := json.MarshalIndent(exportErrors, "", "\t")
+	if err != nil {
+		return fmt.Errorf("JSON marshalling the export errors: %v", err)
+	}
+	output := exportErrorsFile(filepath.Join(meta.Workspace(), ArmTemplateFileName))
+	if err := os.WriteFile(output, b, 0644); err != nil {
+		return fmt.Errorf("writing the export errors to %s: %v", output, err)
+	}
+	return nil
+}
 
-	exportOpt := "SkipAllParameterization"
-	resourceOpt := "*"
-	poller, err := client.BeginExportTemplate(ctx, meta.resourceGroup, armresources.ExportTemplateRequest{
-		Resources: []*string{&resourceOpt},
-		Options:   &exportOpt,
-	}, nil)
+// discoverResources discovers the resources in the resource group as an ARM template, which is then tweaked and
+// converted to the TF resources.
+func (meta *MetaRgImpl) discoverResources(ctx context.Context) error {
+	result, err := meta.discoverer.discover(ctx, meta.resourceGroup)
 	if err != nil {
-		return fmt.Errorf("exporting arm template of resource group %s: %w", meta.resourceGroup, err)
+		return err
+	}
+	tpl := result.template
+	meta.exportErrors = result.exportErrors
+	// The raw template is marshalled before the template gets tweaked in place.
+	raw := result.raw
+	if raw == nil {
+		raw = tpl
 	}
-	resp, err := poller.PollUntilDone(ctx, &runtime.PollUntilDoneOptions{Frequency: 10 * time.Second})
+	meta.rawTemplate, err = json.MarshalIndent(raw, "", "\t")
 	if err != nil {
-		return fmt.Errorf("waiting for exporting arm template of resource group %s: %w", meta.resourceGroup, err)
+		return fmt.Errorf("JSON marshalling the arm template: %v", err)
 	}
 
-	// The response has been read into the ".Template" field as an interface, and the reader has been drained.
-	// As we have defined some (useful) types for the arm template, so we will do a json marshal then unmarshal here
-	// to convert the ".Template" (interface{}) into that artificial type.
-	raw, err := json.Marshal(resp.ResourceGroupExportResult.Template)
-	if err != nil {
-		return fmt.Errorf("marshalling the template: %w", err)
+	opts := armtemplate.TweakOptions{
+		SubscriptionId:   meta.subscriptionId,
+		ResourceGroup:    meta.resourceGroup,
+		ManagedResources: meta.managedResources,
 	}
-	var tpl armtemplate.Template
-	if err := json.Unmarshal(raw, &tpl); err != nil {
-		return fmt.Errorf("unmarshalling the template: %w", err)
+	// In offline mode, the managed resources are only populated from the properties in the template, not recursively.
+	if !meta.offline {
+		resClient, err := meta.Meta.clientBuilder.NewResourcesClient(meta.subscriptionId)
+		if err != nil {
+			return fmt.Errorf("building resources client: %v", err)
+		}
+		opts.GetProperties = func(id armtemplate.ResourceId, apiVersion string) (interface{}, error) {
+			resp, err := resClient.GetByID(ctx, id.ID(meta.subscriptionId, meta.resourceGroup), apiVersion, nil)
+			if err != nil {
+				return nil, err
+			}
+			return resp.Properties, nil
+		}
 	}
-	if err := tpl.TweakResources(); err != nil {
+	if err := tpl.TweakResources(opts); err != nil {
 		return fmt.Errorf("populating managed resources in the ARM template: %v", err)
 	}
-	meta.resources = tpl.ToTFResources(meta.subscriptionId, meta.resourceGroup)
+	meta.tweakedTemplate = tpl
+	meta.resources = tpl.ToTFResources(meta.subscriptionId, meta.resourceGroup, !meta.offline)
 	return nil
 }
 
 func (meta MetaRgImpl) resolveDependency(configs ConfigInfos) (ConfigInfos, error) {
 	configSet := map[string]ConfigInfo{}
+	var ids []string
+	var synthetics ConfigInfos
 	for _, cfg := range configs {
+		if cfg.Synthetic {
+			synthetics = append(synthetics, cfg)
+			continue
+		}
 		configSet[cfg.ResourceID] = cfg
+		ids = append(ids, cfg.ResourceID)
+	}
+
+	var dataSources map[string]dataSource
+	if meta.externalDataSources {
+		var err error
+		dataSources, err = meta.buildDataSources(configs, configSet)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Iterate each config to add dependency by querying the dependency info from arm template.
+	// Iterate each config in dependency order to add dependency by querying the dependency info from arm template.
+	// This guarantees the resource blocks are written in a deterministic order, with the dependencies come first.
 	var out ConfigInfos
+	// The data sources come first, as they are referenced by the resources.
+	for _, ds := range sortedDataSources(dataSources) {
+		out = append(out, ds.configInfo())
+	}
 	rgid := armtemplate.ResourceGroupId.ID(meta.subscriptionId, meta.resourceGroup)
-	for tfid, cfg := range configSet {
+	for _, tfid := range meta.resources.SortByDependency(ids) {
+		cfg := configSet[tfid]
 		if tfid == rgid {
 			out = append(out, cfg)
 			continue
@@ -181,7 +367,16 @@ func (meta MetaRgImpl) resolveDependency(configs ConfigInfos) (ConfigInfos, erro
 			return nil, fmt.Errorf("can't find resource %q in the arm template's resources", tfid)
 		}
 
-		if err := hclBlockAppendDependency(cfg.hcl.Body().Blocks()[0].Body(), tfres.DependsOn, configSet); err != nil {
+		if err := hclBlockAppendDependency(cfg.hcl.Body().Blocks()[0].Body(), tfres.DependsOn, configSet, dataSources); err != nil {
+			return nil, err
+		}
+		out = append(out, cfg)
+	}
+
+	// The synthetic resources are not in the arm template's resources. They depend on the resources they derive from,
+	// while nothing depends on them, so they are placed at last.
+	for _, cfg := range synthetics {
+		if err := hclBlockAppendDependency(cfg.hcl.Body().Blocks()[0].Body(), cfg.DependsOn, configSet, dataSources); err != nil {
 			return nil, err
 		}
 		out = append(out, cfg)
@@ -189,3 +384,68 @@ func (meta MetaRgImpl) resolveDependency(configs ConfigInfos) (ConfigInfos, erro
 
 	return out, nil
 }
+
+// buildDataSources builds the data sources for the resources that are depended on, but not imported (e.g. those in other
+// resource groups), keyed by the id in the dependencies. The resources whose data source can't be built are skipped.
+func (meta MetaRgImpl) buildDataSources(configs ConfigInfos, configSet map[string]ConfigInfo) (map[string]dataSource, error) {
+	deps := map[string]bool{}
+	for _, cfg := range configs {
+		dependsOn := cfg.DependsOn
+		if !cfg.Synthetic {
+			dependsOn = meta.resources[cfg.ResourceID].DependsOn
+		}
+		for _, dep := range dependsOn {
+			if _, ok := configSet[dep]; !ok {
+				deps[dep] = true
+			}
+		}
+	}
+	var ids []string
+	for id := range deps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// The data sources are named in the same pattern as the resources, e.g. "res-ext-0" for the pattern "res-". When
+	// appending to an existing workspace, the names of the data sources already there are skipped.
+	existing := map[tfaddr.TFAddr]bool{}
+	if !meta.empty {
+		var err error
+		existing, err = workspaceDataSources(meta.outdir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	dataSources := map[string]dataSource{}
+	nameIndex := 0
+	for _, id := range ids {
+		// The resources in the template that are not imported are depended on by their TF ids.
+		azureId := id
+		if res, ok := meta.resources[id]; ok {
+			azureId = res.AzureId
+		}
+		ds, err := newDataSource(azureId, fmt.Sprintf("%sext-%d%s", meta.resourceNamePrefix, nameIndex, meta.resourceNameSuffix))
+		if err != nil {
+			log.Printf("[WARN] No data source is generated for %s: %v", azureId, err)
+			continue
+		}
+		for existing[ds.TFAddr] {
+			nameIndex++
+			ds.TFAddr.Name = fmt.Sprintf("%sext-%d%s", meta.resourceNamePrefix, nameIndex, meta.resourceNameSuffix)
+		}
+		nameIndex++
+		dataSources[id] = *ds
+	}
+	return dataSources, nil
+}
+
+func sortedDataSources(dataSources map[string]dataSource) []dataSource {
+	var out []dataSource
+	for _, ds := range dataSources {
+		out = append(out, ds)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].TFAddr.Name < out[j].TFAddr.Name
+	})
+	return out
+}
@@ -0,0 +1,241 @@
+package meta
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/aztfy/internal/armtemplate"
+	"github.com/Azure/aztfy/internal/client"
+	"github.com/Azure/aztfy/internal/config"
+	"github.com/Azure/aztfy/internal/tfaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetaRgImplOffline(t *testing.T) {
+	const (
+		rgId   = "/subscriptions/123/resourceGroups/rg"
+		vnetId = rgId + "/providers/Microsoft.Network/virtualNetworks/vnet"
+	)
+	dir := t.TempDir()
+	tplFile := filepath.Join(dir, "template.json")
+	require.NoError(t, os.WriteFile(tplFile, []byte(`{
+	"$schema": "https://schema.management.azure.com/schemas/2015-01-01/deploymentTemplate.json#",
+	"resources": [
+		{
+			"type": "Microsoft.Network/virtualNetworks",
+			"name": "vnet",
+			"properties": {
+				"addressSpace": {
+					"addressPrefixes": ["10.0.0.0/16"]
+				}
+			}
+		}
+	]
+}`), 0644))
+
+	meta := &MetaRgImpl{
+		Meta: Meta{
+			subscriptionId: "123",
+			outdir:         dir,
+		},
+		resourceGroup:      "rg",
+		resourceNamePrefix: "res-",
+		discoverer:         fileDiscoverer{path: tplFile},
+		offline:            true,
+	}
+
+	l, err := meta.ListResource()
+	require.NoError(t, err)
+	require.Equal(t, ImportList{
+		{
+			ResourceID:      rgId,
+			AzureResourceID: rgId,
+			TFAddr:          tfaddr.TFAddr{Type: "azurerm_resource_group", Name: "res-0"},
+			IsRecommended:   true,
+			Recommendations: []string{"azurerm_resource_group"},
+		},
+		{
+			ResourceID:      vnetId,
+			AzureResourceID: vnetId,
+			DependsOn:       []string{rgId},
+			TFAddr:          tfaddr.TFAddr{Type: "azurerm_virtual_network", Name: "res-1"},
+			IsRecommended:   true,
+			Recommendations: []string{"azurerm_virtual_network"},
+		},
+	}, l)
+
+	require.NoError(t, meta.ExportArmTemplate())
+
+	// The raw template is kept as is.
+	b, err := os.ReadFile(filepath.Join(dir, ArmTemplateFileName))
+	require.NoError(t, err)
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &raw))
+	require.Contains(t, raw, "$schema")
+
+	// The tweaked template contains the resource group, which can be read back.
+	b, err = os.ReadFile(filepath.Join(dir, TweakedArmTemplateFileName))
+	require.NoError(t, err)
+	var tweaked armtemplate.Template
+	require.NoError(t, json.Unmarshal(b, &tweaked))
+	require.Len(t, tweaked.Resources, 2)
+	require.Equal(t, armtemplate.ResourceGroupId, tweaked.Resources[1].ResourceId)
+	require.Equal(t, armtemplate.ResourceIds{armtemplate.ResourceGroupId}, tweaked.Resources[0].DependsOn)
+}
+
+func TestNewRgMetaOffline(t *testing.T) {
+	// Building any client (including the credential) fails, as the cassette to replay doesn't exist.
+	t.Setenv(client.RecorderModeEnvVar, client.RecorderModeReplay)
+	t.Setenv(client.RecorderCassetteEnvVar, filepath.Join(t.TempDir(), "not-exist.json"))
+	t.Setenv(client.MetadataHostEnvVar, "")
+	t.Setenv(client.EnvironmentFileEnvVar, "")
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	query := armtemplate.QueryTypeAndId
+	armtemplate.QueryTypeAndId = func(id string, allowAPI bool) ([]string, []string, error) {
+		if allowAPI {
+			t.Errorf("unexpected API call to query the TF resource type and id of %s", id)
+		}
+		return query(id, allowAPI)
+	}
+	defer func() { armtemplate.QueryTypeAndId = query }()
+
+	dir := t.TempDir()
+	tplFile := filepath.Join(dir, "template.json")
+	require.NoError(t, os.WriteFile(tplFile, []byte(`{
+	"resources": [
+		{
+			"type": "Microsoft.Network/virtualNetworks",
+			"name": "vnet"
+		}
+	]
+}`), 0644))
+
+	meta, err := NewRgMeta(config.RgConfig{
+		CommonConfig: config.CommonConfig{
+			SubscriptionId: "123",
+			Auth:           client.AuthDefault,
+			OutputDir:      t.TempDir(),
+			BatchMode:      true,
+		},
+		ResourceGroupName:   "rg",
+		ResourceNamePattern: "res-",
+		ArmTemplateFile:     tplFile,
+	})
+	require.NoError(t, err)
+
+	l, err := meta.ListResource()
+	require.NoError(t, err)
+	require.Len(t, l, 2)
+	require.Equal(t, "azurerm_virtual_network", l[1].TFAddr.Type)
+}
+
+func TestMetaRgImplListResourceAssociationName(t *testing.T) {
+	const (
+		rgId     = "/subscriptions/123/resourceGroups/rg"
+		vnetId   = rgId + "/providers/Microsoft.Network/virtualNetworks/vnet"
+		subnetId = vnetId + "/subnets/subnet"
+		nsgId    = rgId + "/providers/Microsoft.Network/networkSecurityGroups/nsg"
+	)
+	meta := &MetaRgImpl{
+		Meta: Meta{
+			subscriptionId: "123",
+			outdir:         t.TempDir(),
+		},
+		resourceGroup:      "rg",
+		resourceNamePrefix: "res-",
+		discoverer: discoverFunc(func(context.Context, string) (*discovery, error) {
+			return &discovery{
+				template: &armtemplate.Template{
+					Resources: []armtemplate.Resource{
+						{ResourceId: armtemplate.ResourceId{Type: "Microsoft.Network/virtualNetworks", Name: "vnet"}},
+						{
+							ResourceId: armtemplate.ResourceId{Type: "Microsoft.Network/virtualNetworks/subnets", Name: "vnet/subnet"},
+							Properties: map[string]interface{}{
+								"networkSecurityGroup": map[string]interface{}{
+									"id": "[resourceId('Microsoft.Network/networkSecurityGroups', 'nsg')]",
+								},
+							},
+						},
+						{ResourceId: armtemplate.ResourceId{Type: "Microsoft.Network/networkSecurityGroups", Name: "nsg"}},
+					},
+				},
+			}, nil
+		}),
+		offline: true,
+		// The name of the vnet takes the name that would be generated for the association resource.
+		resourceMapping: map[string]tfaddr.TFAddr{
+			rgId:     {Type: "azurerm_resource_group", Name: "res-0"},
+			vnetId:   {Type: "azurerm_virtual_network", Name: "res-4"},
+			subnetId: {Type: "azurerm_subnet", Name: "res-2"},
+			nsgId:    {Type: "azurerm_network_security_group", Name: "res-3"},
+		},
+	}
+
+	l, err := meta.ListResource()
+	require.NoError(t, err)
+	require.Len(t, l, 5)
+	assoc := l[4]
+	require.True(t, assoc.Synthetic)
+	require.Equal(t, tfaddr.TFAddr{Type: "azurerm_subnet_network_security_group_association", Name: "res-5"}, assoc.TFAddr)
+}
+
+func TestMetaRgImplExportArmTemplateWithErrors(t *testing.T) {
+	const (
+		rgId   = "/subscriptions/123/resourceGroups/rg"
+		vnetId = rgId + "/providers/Microsoft.Network/virtualNetworks/vnet"
+		siteId = rgId + "/providers/Microsoft.Web/sites/site"
+	)
+	dir := t.TempDir()
+	meta := &MetaRgImpl{
+		Meta: Meta{
+			subscriptionId: "123",
+			outdir:         dir,
+		},
+		resourceGroup: "rg",
+		discoverer: discoverFunc(func(context.Context, string) (*discovery, error) {
+			return &discovery{
+				template: &armtemplate.Template{
+					Resources: []armtemplate.Resource{
+						{ResourceId: armtemplate.ResourceId{Type: "Microsoft.Network/virtualNetworks", Name: "vnet"}},
+						{ResourceId: armtemplate.ResourceId{Type: "Microsoft.Web/sites", Name: "site"}},
+					},
+				},
+				raw: map[string]interface{}{
+					"resources": []interface{}{
+						map[string]interface{}{"type": "Microsoft.Network/virtualNetworks", "name": "vnet"},
+					},
+				},
+				exportErrors: map[string]string{
+					strings.ToLower(siteId): "ExportTemplateProviderError",
+				},
+			}, nil
+		}),
+		offline: true,
+	}
+	require.NoError(t, meta.ExportArmTemplate())
+
+	// The export errors are kept alongside the template, in the original case of the resource ids.
+	b, err := os.ReadFile(filepath.Join(dir, "aztfyArmTemplate.errors.json"))
+	require.NoError(t, err)
+	var exportErrors map[string]string
+	require.NoError(t, json.Unmarshal(b, &exportErrors))
+	require.Equal(t, map[string]string{siteId: "ExportTemplateProviderError"}, exportErrors)
+
+	// Reading the template back keeps the failed resources, together with their export errors.
+	meta.discoverer = fileDiscoverer{path: filepath.Join(dir, ArmTemplateFileName)}
+	l, err := meta.ListResource()
+	require.NoError(t, err)
+	var failed []string
+	for _, item := range l {
+		if item.ExportError != nil {
+			failed = append(failed, item.AzureResourceID)
+			require.EqualError(t, item.ExportError, "ExportTemplateProviderError")
+		}
+	}
+	require.Equal(t, []string{siteId}, failed)
+}
@@ -0,0 +1,240 @@
+package meta
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/aztfy/internal/client"
+	"github.com/Azure/aztfy/internal/tfaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMeta(t *testing.T, empty bool) (*Meta, *fakeTerraform) {
+	dir := t.TempDir()
+	tf := newFakeTerraform(dir)
+	return &Meta{
+		rootdir:         t.TempDir(),
+		outdir:          dir,
+		tf:              tf,
+		backendType:     "local",
+		useSafeFilename: !empty,
+		empty:           empty,
+	}, tf
+}
+
+func TestMetaInitProvider(t *testing.T) {
+	t.Run("empty workspace", func(t *testing.T) {
+		meta, tf := newTestMeta(t, true)
+		meta.backendConfig = []string{"path=foo.tfstate"}
+		require.NoError(t, meta.initProvider(context.Background()))
+		require.True(t, tf.initialized)
+		require.Equal(t, []string{"path=foo.tfstate"}, tf.backendConfig)
+		b, err := os.ReadFile(filepath.Join(meta.outdir, "provider.tf"))
+		require.NoError(t, err)
+		require.Equal(t, meta.providerConfig(), string(b))
+	})
+
+	t.Run("append to workspace without provider setting", func(t *testing.T) {
+		meta, tf := newTestMeta(t, false)
+		require.NoError(t, os.WriteFile(filepath.Join(meta.outdir, "main.tf"), []byte("# existing\n"), 0644))
+		require.NoError(t, meta.initProvider(context.Background()))
+		require.False(t, tf.initialized)
+		b, err := os.ReadFile(filepath.Join(meta.outdir, "provider.aztfy.tf"))
+		require.NoError(t, err)
+		require.Equal(t, "provider \"azurerm\" {\n  features {}\n}\n", string(b))
+		// The provider setting is appended to the output directory, rather than the current working directory.
+		wd, err := os.Getwd()
+		require.NoError(t, err)
+		_, err = os.Stat(filepath.Join(wd, "provider.aztfy.tf"))
+		require.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("append to workspace with provider setting", func(t *testing.T) {
+		meta, _ := newTestMeta(t, false)
+		require.NoError(t, os.WriteFile(filepath.Join(meta.outdir, "main.tf"), []byte("provider \"azurerm\" {\n  features {}\n}\n"), 0644))
+		require.NoError(t, meta.initProvider(context.Background()))
+		_, err := os.Stat(filepath.Join(meta.outdir, "provider.aztfy.tf"))
+		require.True(t, os.IsNotExist(err))
+	})
+}
+
+func TestMetaProviderBlock(t *testing.T) {
+	cases := []struct {
+		name   string
+		env    client.Environment
+		expect string
+	}{
+		{
+			name: "default",
+			expect: `provider "azurerm" {
+  features {}
+}
+`,
+		},
+		{
+			name: "public cloud",
+			env:  client.Environment{Name: "public"},
+			expect: `provider "azurerm" {
+  features {}
+}
+`,
+		},
+		{
+			name: "builtin cloud",
+			env:  client.Environment{Name: "china"},
+			expect: `provider "azurerm" {
+  environment = "china"
+
+  features {}
+}
+`,
+		},
+		{
+			name: "custom cloud",
+			env:  client.Environment{Name: "AzureStackCloud", MetadataHost: "management.local.azurestack.external"},
+			expect: `provider "azurerm" {
+  environment   = "AzureStackCloud"
+  metadata_host = "management.local.azurestack.external"
+
+  features {}
+}
+`,
+		},
+	}
+	for _, c := range cases {
+		meta, _ := newTestMeta(t, true)
+		meta.environment = c.env
+		require.Equal(t, c.expect, meta.providerBlock(), c.name)
+	}
+}
+
+func TestMetaImport(t *testing.T) {
+	meta, tf := newTestMeta(t, true)
+	require.NoError(t, meta.initProvider(context.Background()))
+	tf.importErrors["/subscriptions/123/resourceGroups/rg2"] = fmt.Errorf("resource not found")
+
+	cases := []struct {
+		name     string
+		item     ImportItem
+		imported bool
+		err      string
+	}{
+		{
+			name:     "import succeeded",
+			item:     ImportItem{ResourceID: "/subscriptions/123/resourceGroups/rg1", TFAddr: tfaddr.TFAddr{Type: "azurerm_resource_group", Name: "res-0"}},
+			imported: true,
+		},
+		{
+			name: "import failed",
+			item: ImportItem{ResourceID: "/subscriptions/123/resourceGroups/rg2", TFAddr: tfaddr.TFAddr{Type: "azurerm_resource_group", Name: "res-1"}},
+			err:  "resource not found",
+		},
+		{
+			name: "already imported",
+			item: ImportItem{ResourceID: "/subscriptions/123/resourceGroups/rg1", TFAddr: tfaddr.TFAddr{Type: "azurerm_resource_group", Name: "res-0"}},
+			err:  "resource already managed by Terraform: azurerm_resource_group.res-0",
+		},
+	}
+	for _, c := range cases {
+		item := c.item
+		meta.Import(&item)
+		require.Equal(t, c.imported, item.Imported, c.name)
+		if c.err == "" {
+			require.NoError(t, item.ImportError, c.name)
+		} else {
+			require.EqualError(t, item.ImportError, c.err, c.name)
+		}
+		// The temporary config for import is cleaned up.
+		_, err := os.Stat(filepath.Join(meta.outdir, meta.filenameTmpCfg()))
+		require.True(t, os.IsNotExist(err), c.name)
+	}
+	require.Equal(t, map[string]string{"azurerm_resource_group.res-0": "/subscriptions/123/resourceGroups/rg1"}, tf.state)
+
+	meta.CleanTFState("azurerm_resource_group.res-0")
+	require.Empty(t, tf.state)
+}
+
+func TestMetaGenerateCfg(t *testing.T) {
+	const addOutput = `Acquiring state lock. This may take a few moments...
+resource "azurerm_resource_group" "res-0" {
+  name     = "rg1"
+  location = "westeurope"
+}
+Releasing state lock. This may take a few moments...
+
+`
+	l := ImportList{
+		{ResourceID: "/subscriptions/123/resourceGroups/rg1", TFAddr: tfaddr.TFAddr{Type: "azurerm_resource_group", Name: "res-0"}},
+		{ResourceID: "/subscriptions/123/resourceGroups/rg2", TFAddr: tfaddr.TFAddr{Type: "azurerm_resource_group", Name: "res-1"}},
+		{ResourceID: "/subscriptions/123/resourceGroups/rg3"},
+	}
+	const expect = `resource "azurerm_resource_group" "res-0" {
+  name     = "rg1"
+  location = "westeurope"
+}
+`
+
+	t.Run("empty workspace", func(t *testing.T) {
+		meta, tf := newTestMeta(t, true)
+		require.NoError(t, meta.initProvider(context.Background()))
+		tf.addOutputs["azurerm_resource_group.res-0"] = addOutput
+		tf.importErrors["/subscriptions/123/resourceGroups/rg2"] = fmt.Errorf("resource not found")
+		for i := range l {
+			if !l[i].Skip() {
+				meta.Import(&l[i])
+			}
+		}
+		require.NoError(t, meta.GenerateCfg(l))
+		b, err := os.ReadFile(filepath.Join(meta.outdir, "main.tf"))
+		require.NoError(t, err)
+		require.Equal(t, expect, string(b))
+	})
+
+	t.Run("append to workspace", func(t *testing.T) {
+		meta, tf := newTestMeta(t, false)
+		const existing = "resource \"azurerm_resource_group\" \"existing\" {\n}\n"
+		require.NoError(t, os.WriteFile(filepath.Join(meta.outdir, "main.tf"), []byte(existing), 0644))
+		require.NoError(t, meta.initProvider(context.Background()))
+		tf.initialized = true
+		tf.addOutputs["azurerm_resource_group.res-0"] = addOutput
+		l := ImportList{l[0]}
+		meta.Import(&l[0])
+		require.NoError(t, meta.GenerateCfg(l))
+
+		b, err := os.ReadFile(filepath.Join(meta.outdir, "main.tf"))
+		require.NoError(t, err)
+		require.Equal(t, existing, string(b))
+		b, err = os.ReadFile(filepath.Join(meta.outdir, "main.aztfy.tf"))
+		require.NoError(t, err)
+		require.Equal(t, expect, string(b))
+	})
+}
+
+func TestCleanupTerraformAdd(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		expect string
+	}{
+		{
+			name:   "no state lock log",
+			input:  "resource \"foo\" \"bar\" {\n}\n",
+			expect: "resource \"foo\" \"bar\" {\n}",
+		},
+		{
+			name:   "state lock log",
+			input:  "Acquiring state lock. This may take a few moments...\nresource \"foo\" \"bar\" {\n}\nReleasing state lock. This may take a few moments...\n\n",
+			expect: "resource \"foo\" \"bar\" {\n}",
+		},
+		{
+			name:  "state lock log only",
+			input: "Acquiring state lock. This may take a few moments...\nReleasing state lock. This may take a few moments...\n",
+		},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.expect, Meta{}.cleanupTerraformAdd(c.input), c.name)
+	}
+}
@@ -0,0 +1,236 @@
+package meta
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// RewriteRules is a list of declarative rules to rewrite the generated resource blocks.
+// The rules are applied in order, each rule applies to all the resource blocks that it matches.
+type RewriteRules []RewriteRule
+
+// RewriteRule rewrites the resource blocks that it matches.
+// The names in "rename", "remove" and "set" can be a dot separated path to address the attributes in the nested blocks,
+// e.g. "os_disk.caching" addresses the "caching" attribute in all the "os_disk" blocks.
+type RewriteRule struct {
+	// ResourceType is a glob pattern to match the Terraform resource type (e.g. "azurerm_*"). Empty means to match any.
+	ResourceType string `json:"resource_type,omitempty"`
+	// ResourceId is a glob pattern to match the Azure resource id (case insensitive). Empty means to match any.
+	ResourceId string `json:"resource_id,omitempty"`
+
+	// Rename renames the attributes. The key is the old name, the value is the new name. The renames are applied in the
+	// order of the old names, and can't be chained (i.e. a new name can't be another old name).
+	Rename map[string]string `json:"rename,omitempty"`
+	// Remove removes the attributes or nested blocks.
+	Remove []string `json:"remove,omitempty"`
+	// Set sets the attributes, in the order of the names. The key is the name, the value is the HCL expression.
+	Set map[string]string `json:"set,omitempty"`
+	// Lifecycle adds the lifecycle meta arguments.
+	Lifecycle *RewriteRuleLifecycle `json:"lifecycle,omitempty"`
+}
+
+type RewriteRuleLifecycle struct {
+	IgnoreChanges       []string `json:"ignore_changes,omitempty"`
+	PreventDestroy      *bool    `json:"prevent_destroy,omitempty"`
+	CreateBeforeDestroy *bool    `json:"create_before_destroy,omitempty"`
+}
+
+func LoadRewriteRules(path string) (RewriteRules, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading rewrite rules file %s: %v", path, err)
+	}
+	var rules RewriteRules
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("unmarshalling the rewrite rules file %s: %v", path, err)
+	}
+	for i, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i, err)
+		}
+		for name, expr := range rule.Set {
+			if _, err := parseHCLExpr(name, expr); err != nil {
+				return nil, fmt.Errorf("rule %d: %v", i, err)
+			}
+		}
+	}
+	return rules, nil
+}
+
+func (rule RewriteRule) validate() error {
+	for _, from := range sortedKeys(rule.Rename) {
+		to := rule.Rename[from]
+		if _, ok := rule.Rename[to]; ok && to != from {
+			return fmt.Errorf("renaming %q to %q: can't chain renames, as %q is also renamed", from, to, to)
+		}
+	}
+	return nil
+}
+
+func (rule RewriteRule) Match(item ImportItem) bool {
+	if rule.ResourceType != "" && !globMatch(rule.ResourceType, item.TFAddr.Type, false) {
+		return false
+	}
+	if rule.ResourceId != "" {
+		id := item.AzureResourceID
+		if id == "" {
+			id = item.ResourceID
+		}
+		if !globMatch(rule.ResourceId, id, true) {
+			return false
+		}
+	}
+	return true
+}
+
+// rewriteRulesTransformer returns a TFConfigTransformer that applies the rewrite rules.
+func rewriteRulesTransformer(rules RewriteRules) TFConfigTransformer {
+	return func(configs ConfigInfos) (ConfigInfos, error) {
+		out := make(ConfigInfos, len(configs))
+		for i, cfg := range configs {
+			out[i] = cfg
+			if cfg.DataSource {
+				continue
+			}
+			for j, rule := range rules {
+				if !rule.Match(cfg.ImportItem) {
+					continue
+				}
+				if err := rule.apply(cfg.hcl.Body().Blocks()[0].Body()); err != nil {
+					return nil, fmt.Errorf("applying rewrite rule %d on %s: %v", j, cfg.TFAddr, err)
+				}
+			}
+		}
+		return out, nil
+	}
+}
+
+func (rule RewriteRule) apply(body *hclwrite.Body) error {
+	if err := rule.validate(); err != nil {
+		return err
+	}
+	for _, from := range sortedKeys(rule.Rename) {
+		to := rule.Rename[from]
+		fromPath, fromName := splitAttrPath(from)
+		toPath, toName := splitAttrPath(to)
+		if fromPath != toPath {
+			return fmt.Errorf("renaming %q to %q: can't move attribute across blocks", from, to)
+		}
+		for _, b := range hclBodiesByPath(body, fromPath) {
+			attr := b.GetAttribute(fromName)
+			if attr == nil {
+				continue
+			}
+			tokens := attr.Expr().BuildTokens(nil)
+			b.RemoveAttribute(fromName)
+			b.SetAttributeRaw(toName, tokens)
+		}
+	}
+
+	for _, name := range rule.Remove {
+		path, name := splitAttrPath(name)
+		for _, b := range hclBodiesByPath(body, path) {
+			b.RemoveAttribute(name)
+			for _, blk := range b.Blocks() {
+				if blk.Type() == name {
+					b.RemoveBlock(blk)
+				}
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(rule.Set) {
+		expr := rule.Set[name]
+		tokens, err := parseHCLExpr(name, expr)
+		if err != nil {
+			return err
+		}
+		path, name := splitAttrPath(name)
+		for _, b := range hclBodiesByPath(body, path) {
+			b.SetAttributeRaw(name, tokens)
+		}
+	}
+
+	if lc := rule.Lifecycle; lc != nil {
+		if err := hclBlockAppendLifecycle(body, lc.IgnoreChanges); err != nil {
+			return err
+		}
+		if lc.PreventDestroy != nil {
+			hclBlockSetLifecycleArgument(body, "prevent_destroy", cty.BoolVal(*lc.PreventDestroy))
+		}
+		if lc.CreateBeforeDestroy != nil {
+			hclBlockSetLifecycleArgument(body, "create_before_destroy", cty.BoolVal(*lc.CreateBeforeDestroy))
+		}
+	}
+	return nil
+}
+
+// splitAttrPath splits the dot separated attribute path into the path of the nested blocks and the attribute name.
+func splitAttrPath(p string) (string, string) {
+	if pos := strings.LastIndex(p, "."); pos != -1 {
+		return p[:pos], p[pos+1:]
+	}
+	return "", p
+}
+
+// hclBodiesByPath returns the bodies of all the nested blocks addressed by the dot separated block path.
+func hclBodiesByPath(body *hclwrite.Body, path string) []*hclwrite.Body {
+	bodies := []*hclwrite.Body{body}
+	if path == "" {
+		return bodies
+	}
+	for _, seg := range strings.Split(path, ".") {
+		var next []*hclwrite.Body
+		for _, b := range bodies {
+			for _, blk := range b.Blocks() {
+				if blk.Type() == seg {
+					next = append(next, blk.Body())
+				}
+			}
+		}
+		bodies = next
+	}
+	return bodies
+}
+
+func parseHCLExpr(name, expr string) (hclwrite.Tokens, error) {
+	f, diags := hclwrite.ParseConfig([]byte("v = "+expr+"\n"), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("parsing the expression of %q: %s", name, diags.Error())
+	}
+	attr := f.Body().GetAttribute("v")
+	if attr == nil {
+		return nil, fmt.Errorf("parsing the expression of %q: invalid expression %q", name, expr)
+	}
+	return attr.Expr().BuildTokens(nil), nil
+}
+
+// globMatch matches the input against the glob pattern, where "*" matches any sequence of characters (including "/")
+// and "?" matches any single character.
+func globMatch(pattern, input string, caseInsensitive bool) bool {
+	p := regexp.QuoteMeta(pattern)
+	p = strings.ReplaceAll(p, `\*`, `.*`)
+	p = strings.ReplaceAll(p, `\?`, `.`)
+	p = "^" + p + "$"
+	if caseInsensitive {
+		p = "(?i)" + p
+	}
+	return regexp.MustCompile(p).MatchString(input)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
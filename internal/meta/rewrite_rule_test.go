@@ -0,0 +1,147 @@
+package meta
+
+import (
+	"testing"
+
+	"github.com/Azure/aztfy/internal/tfaddr"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteRuleMatch(t *testing.T) {
+	item := ImportItem{
+		ResourceID: "/subscriptions/123/resourceGroups/rg1/providers/Microsoft.Network/publicIPAddresses/pip1",
+		TFAddr:     tfaddr.TFAddr{Type: "azurerm_public_ip", Name: "test"},
+	}
+	cases := []struct {
+		name   string
+		rule   RewriteRule
+		expect bool
+	}{
+		{
+			name:   "match all",
+			rule:   RewriteRule{},
+			expect: true,
+		},
+		{
+			name:   "match type",
+			rule:   RewriteRule{ResourceType: "azurerm_public_*"},
+			expect: true,
+		},
+		{
+			name:   "mismatch type",
+			rule:   RewriteRule{ResourceType: "azurerm_key_vault"},
+			expect: false,
+		},
+		{
+			name:   "match id case insensitively",
+			rule:   RewriteRule{ResourceId: "/subscriptions/*/resourcegroups/rg1/*"},
+			expect: true,
+		},
+		{
+			name:   "match type but mismatch id",
+			rule:   RewriteRule{ResourceType: "azurerm_public_ip", ResourceId: "/subscriptions/*/resourceGroups/rg2/*"},
+			expect: false,
+		},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.expect, c.rule.Match(item), c.name)
+	}
+}
+
+func TestRewriteRuleApply(t *testing.T) {
+	boolTrue := true
+	cases := []struct {
+		name   string
+		rule   RewriteRule
+		input  string
+		expect string
+	}{
+		{
+			name: "rename, remove and set",
+			rule: RewriteRule{
+				Rename: map[string]string{"old": "new", "blk.a": "blk.b"},
+				Remove: []string{"zones", "removed_blk"},
+				Set:    map[string]string{"sku": `"Standard"`},
+			},
+			input: `resource "foo" "test" {
+  old   = 1
+  zones = ["1"]
+  blk {
+    a = 2
+  }
+  removed_blk {
+  }
+}
+`,
+			expect: `resource "foo" "test" {
+  blk {
+    b = 2
+  }
+  new = 1
+  sku = "Standard"
+}
+`,
+		},
+		{
+			name: "renamed and set attributes are appended in the order of the names",
+			rule: RewriteRule{
+				Rename: map[string]string{"d": "z", "a": "y", "c": "x"},
+				Set:    map[string]string{"q": "1", "p": "2"},
+			},
+			input: `resource "foo" "test" {
+  a = 1
+  c = 2
+  d = 3
+}
+`,
+			expect: `resource "foo" "test" {
+  y = 1
+  x = 2
+  z = 3
+  p = 2
+  q = 1
+}
+`,
+		},
+		{
+			name: "lifecycle",
+			rule: RewriteRule{
+				Lifecycle: &RewriteRuleLifecycle{
+					IgnoreChanges:  []string{`tags["CreatedBy"]`},
+					PreventDestroy: &boolTrue,
+				},
+			},
+			input: `resource "foo" "test" {
+  lifecycle {
+    ignore_changes = [tags]
+  }
+}
+`,
+			expect: `resource "foo" "test" {
+  lifecycle {
+    ignore_changes = [
+      tags,
+      tags["CreatedBy"],
+    ]
+    prevent_destroy = true
+  }
+}
+`,
+		},
+	}
+	for _, c := range cases {
+		f, diags := hclwrite.ParseConfig([]byte(c.input), "", hcl.InitialPos)
+		require.False(t, diags.HasErrors(), c.name)
+		require.NoError(t, c.rule.apply(f.Body().Blocks()[0].Body()), c.name)
+		require.Equal(t, c.expect, string(hclwrite.Format(f.Bytes())), c.name)
+	}
+}
+
+func TestRewriteRuleApply_chainedRename(t *testing.T) {
+	rule := RewriteRule{Rename: map[string]string{"a": "b", "b": "c"}}
+	f, diags := hclwrite.ParseConfig([]byte("resource \"foo\" \"test\" {\n  a = 1\n}\n"), "", hcl.InitialPos)
+	require.False(t, diags.HasErrors())
+	require.EqualError(t, rule.apply(f.Body().Blocks()[0].Body()), `renaming "a" to "b": can't chain renames, as "b" is also renamed`)
+}
@@ -0,0 +1,167 @@
+package meta
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario describes how the mock client behaves, which is used to exercise the UI and the error flows without Azure.
+// It is loaded from a YAML (or JSON) file.
+type Scenario struct {
+	// The latency of each operation, except the import.
+	Latency time.Duration `yaml:"latency"`
+
+	// The latency of importing each resource, unless overridden by the resource.
+	ImportLatency time.Duration `yaml:"import_latency"`
+
+	// The errors returned by the operations.
+	InitError     string `yaml:"init_error"`
+	ListError     string `yaml:"list_error"`
+	GenerateError string `yaml:"generate_error"`
+
+	// The resources in the resource group, in the listed order.
+	Resources []ScenarioResource `yaml:"resources"`
+}
+
+type ScenarioResource struct {
+	// The resource id, which is used both as the Azure resource id and the TF resource id.
+	Id string `yaml:"id"`
+
+	// The TF resource type that the resource is mapped to. Empty means the resource is skipped, unless the user picks one.
+	Type string `yaml:"type"`
+
+	// The recommended TF resource types.
+	Recommendations []string `yaml:"recommendations"`
+
+	// The resource ids that the resource depends on.
+	DependsOn []string `yaml:"depends_on"`
+
+	// The error of exporting the resource, in which case the resource is skipped.
+	ExportError string `yaml:"export_error"`
+
+	// The error of importing the resource.
+	ImportError string `yaml:"import_error"`
+
+	// The latency of importing the resource, which overrides the one of the scenario.
+	ImportLatency *time.Duration `yaml:"import_latency"`
+}
+
+// LoadScenario loads the scenario from the file. The latencies default to the ones of the default scenario.
+func LoadScenario(path string) (*Scenario, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading the scenario file %s: %v", path, err)
+	}
+	scenario := Scenario{
+		Latency:       defaultScenario().Latency,
+		ImportLatency: defaultScenario().ImportLatency,
+	}
+	if err := yaml.Unmarshal(b, &scenario); err != nil {
+		return nil, fmt.Errorf("unmarshalling the scenario file %s: %v", path, err)
+	}
+	for i, res := range scenario.Resources {
+		if res.Id == "" {
+			return nil, fmt.Errorf("the %d-th resource of the scenario file %s has no id", i, path)
+		}
+	}
+	return &scenario, nil
+}
+
+// defaultScenario lists a fixed set of resources without recommendation, and never fails.
+func defaultScenario() Scenario {
+	const rgId = "/subscriptions/0000000-0000-0000-0000-00000000000/resourceGroups/example-rg"
+	return Scenario{
+		Latency:       500 * time.Millisecond,
+		ImportLatency: time.Second,
+		Resources: []ScenarioResource{
+			{Id: rgId + "/providers/Microsoft.Network/virtualNetworks/example-network"},
+			{Id: rgId + "/providers/Microsoft.Compute/virtualMachines/example-machine"},
+			{Id: rgId + "/providers/Microsoft.Network/networkInterfaces/example-nic"},
+			{Id: rgId + "/providers/Microsoft.Network/virtualNetworks/example-network/subnets/internal"},
+			{Id: rgId},
+		},
+	}
+}
+
+func newScenario(path string) (*Scenario, error) {
+	if path == "" {
+		scenario := defaultScenario()
+		return &scenario, nil
+	}
+	return LoadScenario(path)
+}
+
+func (s Scenario) resource(id string) (ScenarioResource, bool) {
+	for _, res := range s.Resources {
+		if strings.EqualFold(res.Id, id) {
+			return res, true
+		}
+	}
+	return ScenarioResource{}, false
+}
+
+func scenarioError(msg string) error {
+	if msg == "" {
+		return nil
+	}
+	return errors.New(msg)
+}
+
+// scenarioMeta implements the common operations of the mock clients, as described by the scenario.
+type scenarioMeta struct {
+	scenario Scenario
+}
+
+var _ meta = scenarioMeta{}
+
+func (m scenarioMeta) Init() error {
+	time.Sleep(m.scenario.Latency)
+	return scenarioError(m.scenario.InitError)
+}
+
+func (m scenarioMeta) Workspace() string {
+	return "example-workspace"
+}
+
+func (m scenarioMeta) Import(item *ImportItem) {
+	latency := m.scenario.ImportLatency
+	var importError string
+	if res, ok := m.scenario.resource(item.ResourceID); ok {
+		if res.ImportLatency != nil {
+			latency = *res.ImportLatency
+		}
+		importError = res.ImportError
+	}
+	time.Sleep(latency)
+	item.ImportError = scenarioError(importError)
+	item.Imported = item.ImportError == nil
+}
+
+func (m scenarioMeta) CleanTFState(_ string) {
+	return
+}
+
+func (m scenarioMeta) GenerateCfg(l ImportList) error {
+	time.Sleep(m.scenario.Latency)
+	return scenarioError(m.scenario.GenerateError)
+}
+
+func (m scenarioMeta) GenerateDataSourceCfg(l ImportList) error {
+	time.Sleep(m.scenario.Latency)
+	return scenarioError(m.scenario.GenerateError)
+}
+
+func (m scenarioMeta) Verify(l ImportList) ([]ResourceDiff, error) {
+	time.Sleep(m.scenario.Latency)
+	return nil, nil
+}
+
+func (m scenarioMeta) AutoIgnoreChanges(l ImportList, limit int) ([]IgnoredChange, error) {
+	time.Sleep(m.scenario.Latency)
+	return nil, nil
+}
@@ -0,0 +1,185 @@
+package meta
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Azure/aztfy/internal/config"
+	"github.com/Azure/aztfy/internal/tfaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadScenario(t *testing.T) {
+	dir := t.TempDir()
+	cases := []struct {
+		name    string
+		input   string
+		expect  *Scenario
+		wantErr bool
+	}{
+		{
+			name:  "json with default latencies",
+			input: `{"list_error": "boom", "resources": [{"id": "/subscriptions/123/resourceGroups/rg", "import_latency": "2s"}]}`,
+			expect: &Scenario{
+				Latency:       500 * time.Millisecond,
+				ImportLatency: time.Second,
+				ListError:     "boom",
+				Resources: []ScenarioResource{
+					{
+						Id:            "/subscriptions/123/resourceGroups/rg",
+						ImportLatency: func() *time.Duration { d := 2 * time.Second; return &d }(),
+					},
+				},
+			},
+		},
+		{
+			name:  "yaml",
+			input: "latency: 1s\nresources:\n  - id: /subscriptions/123/resourceGroups/rg\n    type: azurerm_resource_group\n",
+			expect: &Scenario{
+				Latency:       time.Second,
+				ImportLatency: time.Second,
+				Resources: []ScenarioResource{
+					{
+						Id:   "/subscriptions/123/resourceGroups/rg",
+						Type: "azurerm_resource_group",
+					},
+				},
+			},
+		},
+		{
+			name:    "resource without id",
+			input:   `{"resources": [{"type": "azurerm_resource_group"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid latency",
+			input:   `{"latency": "soon"}`,
+			wantErr: true,
+		},
+	}
+	for i, c := range cases {
+		path := filepath.Join(dir, fmt.Sprintf("scenario%d", i))
+		require.NoError(t, os.WriteFile(path, []byte(c.input), 0644), c.name)
+		actual, err := LoadScenario(path)
+		if c.wantErr {
+			require.Error(t, err, c.name)
+			continue
+		}
+		require.NoError(t, err, c.name)
+		require.Equal(t, c.expect, actual, c.name)
+	}
+}
+
+func TestMetaRgDummy(t *testing.T) {
+	const (
+		rgId     = "/subscriptions/123/resourceGroups/rg"
+		vnetId   = rgId + "/providers/Microsoft.Network/virtualNetworks/vnet"
+		subnetId = vnetId + "/subnets/subnet"
+		saId     = rgId + "/providers/Microsoft.Storage/storageAccounts/sa"
+	)
+	m, err := NewRgMeta(config.RgConfig{
+		CommonConfig: config.CommonConfig{
+			MockClient:       true,
+			MockScenarioFile: filepath.Join("testdata", "scenario.yaml"),
+		},
+		ResourceGroupName: "rg",
+	})
+	require.NoError(t, err)
+	require.NoError(t, m.Init())
+
+	l, err := m.ListResource()
+	require.NoError(t, err)
+	require.Equal(t, ImportList{
+		{
+			ResourceID:      rgId,
+			AzureResourceID: rgId,
+			TFAddr:          tfaddr.TFAddr{Type: "azurerm_resource_group", Name: "res-0"},
+			IsRecommended:   true,
+			Recommendations: []string{"azurerm_resource_group"},
+		},
+		{
+			ResourceID:      vnetId,
+			AzureResourceID: vnetId,
+			DependsOn:       []string{rgId},
+			TFAddr:          tfaddr.TFAddr{Type: "azurerm_virtual_network", Name: "res-1"},
+		},
+		{
+			ResourceID:      subnetId,
+			AzureResourceID: subnetId,
+			DependsOn:       []string{vnetId},
+			TFAddr:          tfaddr.TFAddr{Name: "res-2"},
+			Recommendations: []string{"azurerm_subnet"},
+		},
+		{
+			ResourceID:      saId,
+			AzureResourceID: saId,
+			TFAddr:          tfaddr.TFAddr{Name: "res-3"},
+			ExportError:     fmt.Errorf("ResourceTypeNotSupported: the resource type is not supported"),
+		},
+	}, l)
+
+	m.Import(&l[1])
+	require.True(t, l[1].Imported)
+	require.NoError(t, l[1].ImportError)
+
+	l[2].TFAddr.Type = "azurerm_subnet"
+	m.Import(&l[2])
+	require.False(t, l[2].Imported)
+	require.EqualError(t, l[2].ImportError, "subnet not found")
+
+	require.EqualError(t, m.GenerateCfg(l), "generating the configuration failed")
+}
+
+func TestMetaResDummy(t *testing.T) {
+	cases := []struct {
+		name   string
+		id     string
+		rt     string
+		tfid   string
+		errMsg string
+	}{
+		{
+			name: "resource with type",
+			id:   "/subscriptions/123/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet",
+			rt:   "azurerm_virtual_network",
+			tfid: "/subscriptions/123/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet",
+		},
+		{
+			name: "resource with recommendation only",
+			id:   "/subscriptions/123/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet",
+			rt:   "azurerm_subnet",
+			tfid: "/subscriptions/123/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet",
+		},
+		{
+			name:   "resource failed to export",
+			id:     "/subscriptions/123/resourceGroups/rg/providers/Microsoft.Storage/storageAccounts/sa",
+			errMsg: "ResourceTypeNotSupported: the resource type is not supported",
+		},
+		{
+			name:   "resource not in scenario",
+			id:     "/subscriptions/123/resourceGroups/rg2",
+			errMsg: "resource /subscriptions/123/resourceGroups/rg2 is not found in the scenario",
+		},
+	}
+	for _, c := range cases {
+		m, err := NewResMeta(config.ResConfig{
+			CommonConfig: config.CommonConfig{
+				MockClient:       true,
+				MockScenarioFile: filepath.Join("testdata", "scenario.yaml"),
+			},
+			ResourceId: c.id,
+		})
+		require.NoError(t, err, c.name)
+		rt, tfid, err := m.QueryResourceTypeAndId()
+		if c.errMsg != "" {
+			require.EqualError(t, err, c.errMsg, c.name)
+			continue
+		}
+		require.NoError(t, err, c.name)
+		require.Equal(t, c.rt, rt, c.name)
+		require.Equal(t, c.tfid, tfid, c.name)
+	}
+}
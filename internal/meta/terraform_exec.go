@@ -0,0 +1,78 @@
+package meta
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/magodo/tfadd/tfadd"
+)
+
+// terraformExecutor executes the Terraform commands against the workspace (i.e. the output directory).
+type terraformExecutor interface {
+	// Init runs "terraform init", with the backend configs.
+	Init(ctx context.Context, backendConfig []string) error
+
+	// Import runs "terraform import", the resource block of the address is expected to exist in the workspace.
+	Import(ctx context.Context, addr, id string) error
+
+	// StateRm runs "terraform state rm".
+	StateRm(ctx context.Context, addr string) error
+
+	// Add returns the configuration of the resource in the state, in the same form as "terraform add".
+	Add(ctx context.Context, addr string) ([]byte, error)
+
+	// Plan runs "terraform plan", and returns the plan.
+	Plan(ctx context.Context) (*tfjson.Plan, error)
+}
+
+// tfexecExecutor is the terraformExecutor that runs the Terraform binary.
+type tfexecExecutor struct {
+	tf *tfexec.Terraform
+
+	// The directory to store the temporary files (e.g. the plan file).
+	tmpdir string
+}
+
+var _ terraformExecutor = tfexecExecutor{}
+
+func (e tfexecExecutor) Init(ctx context.Context, backendConfig []string) error {
+	var opts []tfexec.InitOption
+	for _, opt := range backendConfig {
+		opts = append(opts, tfexec.BackendConfig(opt))
+	}
+	return e.tf.Init(ctx, opts...)
+}
+
+func (e tfexecExecutor) Import(ctx context.Context, addr, id string) error {
+	return e.tf.Import(ctx, addr, id)
+}
+
+func (e tfexecExecutor) StateRm(ctx context.Context, addr string) error {
+	return e.tf.StateRm(ctx, addr)
+}
+
+func (e tfexecExecutor) Add(ctx context.Context, addr string) ([]byte, error) {
+	return tfadd.State(ctx, e.tf, tfadd.Target(addr))
+}
+
+func (e tfexecExecutor) Plan(ctx context.Context) (*tfjson.Plan, error) {
+	f, err := os.CreateTemp(e.tmpdir, "plan-")
+	if err != nil {
+		return nil, fmt.Errorf("creating the plan file: %v", err)
+	}
+	f.Close()
+	planFile := f.Name()
+	defer os.Remove(planFile)
+
+	if _, err := e.tf.Plan(ctx, tfexec.Out(planFile)); err != nil {
+		return nil, fmt.Errorf("running terraform plan: %v", err)
+	}
+	plan, err := e.tf.ShowPlanFile(ctx, planFile)
+	if err != nil {
+		return nil, fmt.Errorf("showing the plan file: %v", err)
+	}
+	return plan, nil
+}
@@ -0,0 +1,206 @@
+package meta
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Azure/aztfy/internal/tfaddr"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	tfjson "github.com/hashicorp/terraform-json"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// fakeTerraform is an in-memory terraformExecutor, which simulates the state of the workspace.
+type fakeTerraform struct {
+	// The workspace directory, where the resource blocks are looked up on import.
+	dir string
+
+	// Whether "terraform init" has been run, and the backend configs it is run with.
+	initialized   bool
+	backendConfig []string
+
+	// The simulated state. Key is the TF resource address, value is the resource id.
+	state map[string]string
+
+	// The errors to return on importing the resource ids.
+	importErrors map[string]error
+
+	// The output of "terraform add" of the TF resource addresses. Otherwise, it is rendered from the resource in the
+	// provider state that has the same type and id. By default, an empty resource block is returned.
+	addOutputs    map[string]string
+	providerState *tfjson.State
+
+	// The plan to return.
+	plan *tfjson.Plan
+}
+
+var _ terraformExecutor = &fakeTerraform{}
+
+func newFakeTerraform(dir string) *fakeTerraform {
+	return &fakeTerraform{
+		dir:          dir,
+		state:        map[string]string{},
+		importErrors: map[string]error{},
+		addOutputs:   map[string]string{},
+	}
+}
+
+func (tf *fakeTerraform) Init(_ context.Context, backendConfig []string) error {
+	tf.initialized = true
+	tf.backendConfig = backendConfig
+	return nil
+}
+
+func (tf *fakeTerraform) Import(_ context.Context, addr, id string) error {
+	if !tf.initialized {
+		return fmt.Errorf("terraform is not initialized")
+	}
+	if _, ok := tf.state[addr]; ok {
+		return fmt.Errorf("resource already managed by Terraform: %s", addr)
+	}
+	tfAddr, err := tfaddr.ParseTFResourceAddr(addr)
+	if err != nil {
+		return err
+	}
+	if ok, err := tf.hasResourceBlock(*tfAddr); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("resource address %q does not exist in the configuration", addr)
+	}
+	if err := tf.importErrors[id]; err != nil {
+		return err
+	}
+	tf.state[addr] = id
+	return nil
+}
+
+func (tf *fakeTerraform) StateRm(_ context.Context, addr string) error {
+	if _, ok := tf.state[addr]; !ok {
+		return fmt.Errorf("no matching objects found for %s", addr)
+	}
+	delete(tf.state, addr)
+	return nil
+}
+
+func (tf *fakeTerraform) Add(_ context.Context, addr string) ([]byte, error) {
+	if _, ok := tf.state[addr]; !ok {
+		return nil, fmt.Errorf("no state found for %s", addr)
+	}
+	if out, ok := tf.addOutputs[addr]; ok {
+		return []byte(out), nil
+	}
+	tfAddr, err := tfaddr.ParseTFResourceAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	if tf.providerState != nil && tf.providerState.Values != nil && tf.providerState.Values.RootModule != nil {
+		for _, res := range tf.providerState.Values.RootModule.Resources {
+			if id, ok := res.AttributeValues["id"].(string); ok && res.Type == tfAddr.Type && strings.EqualFold(id, tf.state[addr]) {
+				return renderStateResource(*tfAddr, res.AttributeValues)
+			}
+		}
+	}
+	return []byte(fmt.Sprintf("resource %q %q {\n}\n", tfAddr.Type, tfAddr.Name)), nil
+}
+
+func (tf *fakeTerraform) Plan(context.Context) (*tfjson.Plan, error) {
+	if tf.plan == nil {
+		return &tfjson.Plan{}, nil
+	}
+	return tf.plan, nil
+}
+
+// hasResourceBlock tells whether the resource block of the address exists in the workspace, which is required by import.
+func (tf *fakeTerraform) hasResourceBlock(addr tfaddr.TFAddr) (bool, error) {
+	entries, err := os.ReadDir(tf.dir)
+	if err != nil {
+		return false, err
+	}
+	p := regexp.MustCompile(fmt.Sprintf(`(?m)^\s*resource\s+"%s"\s+"%s"\s*{`, regexp.QuoteMeta(addr.Type), regexp.QuoteMeta(addr.Name)))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".tf" {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(tf.dir, entry.Name()))
+		if err != nil {
+			return false, err
+		}
+		if p.Match(b) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// renderStateResource renders the attribute values of a resource in the state as a resource block. As there is no
+// provider schema, the non-empty lists of objects are rendered as nested blocks, and the others as attributes. The
+// "id", the null and the empty values are skipped.
+func renderStateResource(addr tfaddr.TFAddr, values map[string]interface{}) ([]byte, error) {
+	f := hclwrite.NewEmptyFile()
+	if err := renderStateBody(f.Body().AppendNewBlock("resource", []string{addr.Type, addr.Name}).Body(), values, true); err != nil {
+		return nil, fmt.Errorf("rendering %s: %v", addr, err)
+	}
+	return f.Bytes(), nil
+}
+
+func renderStateBody(body *hclwrite.Body, values map[string]interface{}, topLevel bool) error {
+	var keys []string
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := values[k]
+		if topLevel && k == "id" {
+			continue
+		}
+		switch v := v.(type) {
+		case nil:
+			continue
+		case string:
+			if v == "" {
+				continue
+			}
+		case map[string]interface{}:
+			if len(v) == 0 {
+				continue
+			}
+		case []interface{}:
+			if len(v) == 0 {
+				continue
+			}
+			if _, ok := v[0].(map[string]interface{}); ok {
+				for _, e := range v {
+					obj, ok := e.(map[string]interface{})
+					if !ok {
+						return fmt.Errorf("%s: mixed list of objects and non-objects", k)
+					}
+					if err := renderStateBody(body.AppendNewBlock(k, nil).Body(), obj, false); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		ty, err := ctyjson.ImpliedType(b)
+		if err != nil {
+			return err
+		}
+		val, err := ctyjson.Unmarshal(b, ty)
+		if err != nil {
+			return err
+		}
+		body.SetAttributeValue(k, val)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// TransformerInput is the JSON document written to the stdin of an external transformer, for each generated resource block.
+type TransformerInput struct {
+	ImportItem TransformerImportItem `json:"import_item"`
+	// The HCL of the resource block
+	HCL string `json:"hcl"`
+}
+
+type TransformerImportItem struct {
+	ResourceID      string   `json:"resource_id"`
+	AzureResourceID string   `json:"azure_resource_id,omitempty"`
+	TFResourceType  string   `json:"tf_resource_type"`
+	TFResourceName  string   `json:"tf_resource_name"`
+	IsRecommended   bool     `json:"is_recommended"`
+	Recommendations []string `json:"recommendations,omitempty"`
+}
+
+// externalTransformer returns a TFConfigTransformer that runs the specified executable for each resource block (the
+// data source blocks are left as is).
+// The executable reads a TransformerInput from its stdin, and is expected to write the (modified) HCL of the resource block to its stdout.
+func externalTransformer(path string) TFConfigTransformer {
+	return func(configs ConfigInfos) (ConfigInfos, error) {
+		out := make(ConfigInfos, len(configs))
+		for i, cfg := range configs {
+			if cfg.DataSource {
+				out[i] = cfg
+				continue
+			}
+			f, err := runExternalTransformer(path, cfg)
+			if err != nil {
+				return nil, fmt.Errorf("running transformer %s for %s: %v", path, cfg.TFAddr, err)
+			}
+			cfg.hcl = f
+			out[i] = cfg
+		}
+		return out, nil
+	}
+}
+
+func runExternalTransformer(path string, cfg ConfigInfo) (*hclwrite.File, error) {
+	input := TransformerInput{
+		ImportItem: TransformerImportItem{
+			ResourceID:      cfg.ResourceID,
+			AzureResourceID: cfg.AzureResourceID,
+			TFResourceType:  cfg.TFAddr.Type,
+			TFResourceName:  cfg.TFAddr.Name,
+			IsRecommended:   cfg.IsRecommended,
+			Recommendations: cfg.Recommendations,
+		},
+		HCL: string(hclwrite.Format(cfg.hcl.Bytes())),
+	}
+	b, err := json.Marshal(input)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling the input: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path)
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if stdErrStr := strings.TrimSpace(stderr.String()); stdErrStr != "" {
+			err = fmt.Errorf("%v: %s", err, stdErrStr)
+		}
+		return nil, err
+	}
+
+	f, diags := hclwrite.ParseConfig(stdout.Bytes(), "", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("the output is not a valid HCL: %s", diags.Error())
+	}
+	blocks := f.Body().Blocks()
+	if len(blocks) != 1 {
+		return nil, fmt.Errorf("the output is expected to contain exactly one block, got=%d", len(blocks))
+	}
+	if blk := blocks[0]; blk.Type() != "resource" || len(blk.Labels()) != 2 || blk.Labels()[0] != cfg.TFAddr.Type || blk.Labels()[1] != cfg.TFAddr.Name {
+		return nil, fmt.Errorf("the output is expected to be the resource block of %s", cfg.TFAddr)
+	}
+	return f, nil
+}
@@ -0,0 +1,124 @@
+package meta
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/Azure/aztfy/internal/tfaddr"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExternalTransformer(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script based transformers are not supported on Windows")
+	}
+
+	cases := []struct {
+		name   string
+		script string
+		expect string
+		// The expected input of the transformer, if not empty.
+		input string
+		error bool
+	}{
+		{
+			name: "echo input",
+			script: `#!/bin/sh
+cat > /dev/null
+echo 'resource "azurerm_resource_group" "test" {'
+echo '  name = "transformed"'
+echo '}'
+`,
+			expect: `resource "azurerm_resource_group" "test" {
+  name = "transformed"
+}
+`,
+		},
+		{
+			name: "check input",
+			script: `#!/bin/sh
+cat > "$(dirname "$0")/input.json"
+echo 'resource "azurerm_resource_group" "test" {'
+echo '  name = "transformed"'
+echo '}'
+`,
+			expect: `resource "azurerm_resource_group" "test" {
+  name = "transformed"
+}
+`,
+			input: `{
+  "import_item": {
+    "resource_id": "/subscriptions/123/resourceGroups/rg",
+    "azure_resource_id": "/subscriptions/123/resourceGroups/rg",
+    "tf_resource_type": "azurerm_resource_group",
+    "tf_resource_name": "test",
+    "is_recommended": true,
+    "recommendations": ["azurerm_resource_group"]
+  },
+  "hcl": "resource \"azurerm_resource_group\" \"test\" {\n  name = \"rg\"\n}\n"
+}`,
+		},
+		{
+			name: "invalid hcl",
+			script: `#!/bin/sh
+echo 'resource "azurerm_resource_group" "test" {'
+`,
+			error: true,
+		},
+		{
+			name: "address changed",
+			script: `#!/bin/sh
+echo 'resource "azurerm_resource_group" "other" {}'
+`,
+			error: true,
+		},
+		{
+			name: "non-zero exit",
+			script: `#!/bin/sh
+echo 'boom' >&2
+exit 1
+`,
+			error: true,
+		},
+	}
+
+	for _, c := range cases {
+		path := filepath.Join(t.TempDir(), "transformer.sh")
+		require.NoError(t, os.WriteFile(path, []byte(c.script), 0755), c.name)
+
+		f, diags := hclwrite.ParseConfig([]byte(`resource "azurerm_resource_group" "test" {
+  name = "rg"
+}
+`), "", hcl.InitialPos)
+		require.False(t, diags.HasErrors(), c.name)
+		configs := ConfigInfos{
+			{
+				ImportItem: ImportItem{
+					ResourceID:      "/subscriptions/123/resourceGroups/rg",
+					AzureResourceID: "/subscriptions/123/resourceGroups/rg",
+					TFAddr:          tfaddr.TFAddr{Type: "azurerm_resource_group", Name: "test"},
+					IsRecommended:   true,
+					Recommendations: []string{"azurerm_resource_group"},
+				},
+				hcl: f,
+			},
+		}
+
+		out, err := externalTransformer(path)(configs)
+		if c.error {
+			require.Error(t, err, c.name)
+			continue
+		}
+		require.NoError(t, err, c.name)
+		require.Equal(t, c.expect, string(hclwrite.Format(out[0].hcl.Bytes())), c.name)
+		if c.input != "" {
+			b, err := os.ReadFile(filepath.Join(filepath.Dir(path), "input.json"))
+			require.NoError(t, err, c.name)
+			require.JSONEq(t, c.input, string(b), c.name)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package meta
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// ResourceDiff is the diff of a resource shown by "terraform plan".
+type ResourceDiff struct {
+	// The TF resource address
+	TFAddr string
+
+	// The TF resource id, which is empty if the resource is not in the import list (e.g. resources already exist when appending).
+	ResourceID string
+
+	// The planned actions, e.g. "update", "delete,create".
+	Actions []string
+
+	// The top level attributes that have changed.
+	Attributes []string
+}
+
+func (d ResourceDiff) String() string {
+	var s string
+	if d.ResourceID != "" {
+		s = fmt.Sprintf("%s (%s): %s", d.TFAddr, d.ResourceID, strings.Join(d.Actions, ","))
+	} else {
+		s = fmt.Sprintf("%s: %s", d.TFAddr, strings.Join(d.Actions, ","))
+	}
+	if len(d.Attributes) != 0 {
+		s += fmt.Sprintf(" [%s]", strings.Join(d.Attributes, ", "))
+	}
+	return s
+}
+
+// Verify runs "terraform plan" on the output workspace, and returns the diff of each resource, if any.
+func (meta Meta) Verify(l ImportList) ([]ResourceDiff, error) {
+	plan, err := meta.tf.Plan(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return planToResourceDiffs(plan, l), nil
+}
+
+func planToResourceDiffs(plan *tfjson.Plan, l ImportList) []ResourceDiff {
+	addrToId := map[string]string{}
+	for _, item := range l {
+		addrToId[item.TFAddr.String()] = item.ResourceID
+	}
+
+	var diffs []ResourceDiff
+	for _, rc := range plan.ResourceChanges {
+		if rc.Change == nil || rc.Change.Actions.NoOp() || rc.Change.Actions.Read() {
+			continue
+		}
+		var actions []string
+		for _, action := range rc.Change.Actions {
+			actions = append(actions, string(action))
+		}
+		diff := ResourceDiff{
+			TFAddr:     rc.Address,
+			ResourceID: addrToId[rc.Address],
+			Actions:    actions,
+		}
+		if rc.Change.Actions.Update() {
+			diff.Attributes = changedAttributes(rc.Change)
+		}
+		diffs = append(diffs, diff)
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].TFAddr < diffs[j].TFAddr
+	})
+	return diffs
+}
+
+// changedAttributes returns the top level attributes that are changed, including those whose new values are unknown.
+func changedAttributes(change *tfjson.Change) []string {
+	before, _ := change.Before.(map[string]interface{})
+	after, _ := change.After.(map[string]interface{})
+	afterUnknown, _ := change.AfterUnknown.(map[string]interface{})
+
+	set := map[string]bool{}
+	for k, v := range before {
+		if !reflect.DeepEqual(v, after[k]) {
+			set[k] = true
+		}
+	}
+	for k, v := range after {
+		if !reflect.DeepEqual(v, before[k]) {
+			set[k] = true
+		}
+	}
+	for k, v := range afterUnknown {
+		if containsUnknown(v) {
+			set[k] = true
+		}
+	}
+
+	var attrs []string
+	for k := range set {
+		attrs = append(attrs, k)
+	}
+	sort.Strings(attrs)
+	return attrs
+}
+
+// containsUnknown tells whether the value of the "after_unknown" contains any unknown (i.e. true) value.
+func containsUnknown(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case []interface{}:
+		for _, e := range v {
+			if containsUnknown(e) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, e := range v {
+			if containsUnknown(e) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package meta
+
+import (
+	"testing"
+
+	"github.com/Azure/aztfy/internal/tfaddr"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanToResourceDiffs(t *testing.T) {
+	l := ImportList{
+		{
+			ResourceID: "/subscriptions/123/resourceGroups/rg",
+			TFAddr:     tfaddr.TFAddr{Type: "azurerm_resource_group", Name: "test"},
+		},
+		{
+			ResourceID: "/subscriptions/123/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet",
+			TFAddr:     tfaddr.TFAddr{Type: "azurerm_virtual_network", Name: "test"},
+		},
+	}
+	plan := &tfjson.Plan{
+		ResourceChanges: []*tfjson.ResourceChange{
+			{
+				Address: "azurerm_virtual_network.test",
+				Change: &tfjson.Change{
+					Actions: tfjson.Actions{tfjson.ActionUpdate},
+					Before: map[string]interface{}{
+						"name":          "vnet",
+						"address_space": []interface{}{"10.0.0.0/16"},
+						"tags":          map[string]interface{}{},
+					},
+					After: map[string]interface{}{
+						"name":          "vnet",
+						"address_space": []interface{}{"10.0.0.0/8"},
+					},
+					AfterUnknown: map[string]interface{}{
+						"guid":   true,
+						"subnet": []interface{}{false},
+					},
+				},
+			},
+			{
+				Address: "azurerm_resource_group.test",
+				Change: &tfjson.Change{
+					Actions: tfjson.Actions{tfjson.ActionNoop},
+				},
+			},
+			{
+				Address: "azurerm_subnet.other",
+				Change: &tfjson.Change{
+					Actions: tfjson.Actions{tfjson.ActionDelete, tfjson.ActionCreate},
+				},
+			},
+		},
+	}
+
+	require.Equal(t, []ResourceDiff{
+		{
+			TFAddr:  "azurerm_subnet.other",
+			Actions: []string{"delete", "create"},
+		},
+		{
+			TFAddr:     "azurerm_virtual_network.test",
+			ResourceID: "/subscriptions/123/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet",
+			Actions:    []string{"update"},
+			Attributes: []string{"address_space", "guid", "tags"},
+		},
+	}, planToResourceDiffs(plan, l))
+}
@@ -3,6 +3,7 @@ package internal
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/Azure/aztfy/internal/config"
@@ -13,6 +14,15 @@ import (
 	"github.com/magodo/spinner"
 )
 
+// VerifyError indicates that "terraform plan" still shows diff on the generated workspace.
+type VerifyError struct {
+	Diffs []meta.ResourceDiff
+}
+
+func (e VerifyError) Error() string {
+	return fmt.Sprintf("terraform plan shows diff on %d resource(s)", len(e.Diffs))
+}
+
 func ResourceImport(cfg config.ResConfig) error {
 	c, err := meta.NewResMeta(cfg)
 	if err != nil {
@@ -22,7 +32,9 @@ func ResourceImport(cfg config.ResConfig) error {
 	s := bspinner.NewModel()
 	s.Spinner = common.Spinner
 
-	return spinner.Run(s, func(msg spinner.Messager) error {
+	var ignored []meta.IgnoredChange
+	var diffs []meta.ResourceDiff
+	err = spinner.Run(s, func(msg spinner.Messager) error {
 		msg.SetStatus("Initializing...")
 		if err := c.Init(); err != nil {
 			return err
@@ -35,15 +47,33 @@ func ResourceImport(cfg config.ResConfig) error {
 		}
 
 		item := meta.ImportItem{
-			ResourceID: tfid,
+			ResourceID:      tfid,
+			AzureResourceID: c.ResourceId(),
 			TFAddr: tfaddr.TFAddr{
 				Type: rt,
-				Name: c.ResourceName,
+				Name: cfg.ResourceName,
 			},
 		}
 		msg.SetDetail(fmt.Sprintf(`Resource Type: %s
 Resource Id  : %s`, item.TFAddr.Type, item.ResourceID))
 
+		if cfg.DataSource {
+			msg.SetStatus("Generating Terraform data source...")
+			list := meta.ImportList{item}
+			if err := c.GenerateDataSourceCfg(list); err != nil {
+				return fmt.Errorf("generating Terraform data source: %v", err)
+			}
+			if err := list[0].ImportError; err != nil {
+				return fmt.Errorf("failed to generate the data source of %s as %s: %v", item.ResourceID, item.TFAddr, err)
+			}
+			msg.SetStatus("Validating the Terraform data source...")
+			diffs, err = c.Verify(list)
+			if err != nil {
+				return fmt.Errorf("validating the Terraform data source: %v", err)
+			}
+			return nil
+		}
+
 		msg.SetStatus("Importing...")
 		c.Import(&item)
 		if err := item.ImportError; err != nil {
@@ -55,8 +85,35 @@ Resource Id  : %s`, item.TFAddr.Type, item.ResourceID))
 			return fmt.Errorf("generating Terraform configuration: %v", err)
 		}
 
+		if cfg.AutoIgnoreChanges > 0 {
+			msg.SetStatus("Ignoring the residual diff...")
+			ignored, err = c.AutoIgnoreChanges(meta.ImportList{item}, cfg.AutoIgnoreChanges)
+			if err != nil {
+				return fmt.Errorf("ignoring the residual diff: %v", err)
+			}
+		}
+
+		if cfg.Verify {
+			msg.SetStatus("Verifying the Terraform workspace...")
+			diffs, err = c.Verify(meta.ImportList{item})
+			if err != nil {
+				return fmt.Errorf("verifying the Terraform workspace: %v", err)
+			}
+		}
+
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	reportIgnoredChanges(ignored)
+
+	// The data sources are always validated.
+	if cfg.Verify || cfg.DataSource {
+		return reportVerifyResult(diffs)
+	}
+	return nil
 }
 
 func BatchImport(cfg config.RgConfig, continueOnError bool) error {
@@ -69,6 +126,8 @@ func BatchImport(cfg config.RgConfig, continueOnError bool) error {
 	s.Spinner = common.Spinner
 
 	var warnings []string
+	var ignored []meta.IgnoredChange
+	var diffs []meta.ResourceDiff
 	err = spinner.Run(s, func(msg spinner.Messager) error {
 		msg.SetStatus("Initializing...")
 		if err := c.Init(); err != nil {
@@ -81,10 +140,54 @@ func BatchImport(cfg config.RgConfig, continueOnError bool) error {
 			return err
 		}
 
+		for i := range list {
+			if list[i].Skip() {
+				if err := list[i].ExportError; err != nil {
+					warnings = append(warnings, fmt.Sprintf("Failed to export resource: %s, skip it: %v", list[i].ResourceID, err))
+				} else {
+					warnings = append(warnings, fmt.Sprintf("No mapping information for resource: %s, skip it", list[i].ResourceID))
+				}
+				msg.SetDetail(strings.Join(warnings, "\n"))
+			}
+		}
+
+		if cfg.Graph {
+			msg.SetStatus("Exporting the dependency graph...")
+			if err := c.ExportGraph(list); err != nil {
+				return fmt.Errorf("exporting the dependency graph: %v", err)
+			}
+		}
+
+		if cfg.DataSource {
+			msg.SetStatus("Generating Terraform data sources...")
+			if err := c.GenerateDataSourceCfg(list); err != nil {
+				return fmt.Errorf("generating Terraform data sources: %v", err)
+			}
+			for _, item := range list.ImportErrored() {
+				msg := fmt.Sprintf("Failed to generate the data source of %s as %s: %v", item.ResourceID, item.TFAddr, item.ImportError)
+				if !continueOnError {
+					return fmt.Errorf(msg)
+				}
+				warnings = append(warnings, msg)
+			}
+
+			msg.SetStatus("Validating the Terraform data sources...")
+			diffs, err = c.Verify(list)
+			if err != nil {
+				return fmt.Errorf("validating the Terraform data sources: %v", err)
+			}
+			return nil
+		}
+
 		msg.SetStatus("Importing resources...")
 		for i := range list {
 			if list[i].Skip() {
-				warnings = append(warnings, fmt.Sprintf("No mapping information for resource: %s, skip it", list[i].ResourceID))
+				continue
+			}
+			// The list is in dependency order, skip importing the resource whose dependency failed to import (or is blocked).
+			if dep, ok := list.FailedDependency(list[i]); ok {
+				list[i].BlockedReason = fmt.Sprintf("its dependency %s (%s) is not imported", dep.ResourceID, dep.TFAddr)
+				warnings = append(warnings, fmt.Sprintf("Blocked importing %s as %s: %s", list[i].ResourceID, list[i].TFAddr, list[i].BlockedReason))
 				msg.SetDetail(strings.Join(warnings, "\n"))
 				continue
 			}
@@ -103,6 +206,22 @@ func BatchImport(cfg config.RgConfig, continueOnError bool) error {
 		if err := c.GenerateCfg(list); err != nil {
 			return fmt.Errorf("generating Terraform configuration: %v", err)
 		}
+
+		if cfg.AutoIgnoreChanges > 0 {
+			msg.SetStatus("Ignoring the residual diff...")
+			ignored, err = c.AutoIgnoreChanges(list, cfg.AutoIgnoreChanges)
+			if err != nil {
+				return fmt.Errorf("ignoring the residual diff: %v", err)
+			}
+		}
+
+		if cfg.Verify {
+			msg.SetStatus("Verifying the Terraform workspace...")
+			diffs, err = c.Verify(list)
+			if err != nil {
+				return fmt.Errorf("verifying the Terraform workspace: %v", err)
+			}
+		}
 		return nil
 	})
 
@@ -111,5 +230,100 @@ func BatchImport(cfg config.RgConfig, continueOnError bool) error {
 		fmt.Fprintln(os.Stderr, "Warnings:\n"+strings.Join(warnings, "\n"))
 	}
 
-	return err
+	if err != nil {
+		return err
+	}
+
+	reportIgnoredChanges(ignored)
+
+	// The data sources are always validated.
+	if cfg.Verify || cfg.DataSource {
+		return reportVerifyResult(diffs)
+	}
+	return nil
+}
+
+// Graph exports the dependency graph of the resources in the resource group, without importing them.
+func Graph(cfg config.RgConfig) error {
+	c, err := meta.NewRgMeta(cfg)
+	if err != nil {
+		return err
+	}
+
+	s := bspinner.NewModel()
+	s.Spinner = common.Spinner
+
+	err = spinner.Run(s, func(msg spinner.Messager) error {
+		msg.SetStatus("Listing resources...")
+		list, err := c.ListResource()
+		if err != nil {
+			return err
+		}
+
+		msg.SetStatus("Exporting the dependency graph...")
+		if err := c.ExportGraph(list); err != nil {
+			return fmt.Errorf("exporting the dependency graph: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("The dependency graph is exported to %s and %s\n",
+		filepath.Join(c.Workspace(), meta.GraphDOTFileName),
+		filepath.Join(c.Workspace(), meta.GraphJSONFileName))
+	return nil
+}
+
+func ExportArmTemplate(cfg config.RgConfig) error {
+	c, err := meta.NewRgMeta(cfg)
+	if err != nil {
+		return err
+	}
+
+	s := bspinner.NewModel()
+	s.Spinner = common.Spinner
+
+	err = spinner.Run(s, func(msg spinner.Messager) error {
+		msg.SetStatus("Exporting the ARM template...")
+		if err := c.ExportArmTemplate(); err != nil {
+			return fmt.Errorf("exporting the ARM template: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("The ARM template is exported to %s, and the tweaked one is exported to %s\n",
+		filepath.Join(c.Workspace(), meta.ArmTemplateFileName),
+		filepath.Join(c.Workspace(), meta.TweakedArmTemplateFileName))
+	return nil
+}
+
+// reportIgnoredChanges prints out the attributes that are automatically added to the lifecycle.ignore_changes, if any.
+func reportIgnoredChanges(ignored []meta.IgnoredChange) {
+	if len(ignored) == 0 {
+		return
+	}
+	var lines []string
+	for _, ic := range ignored {
+		lines = append(lines, ic.String())
+	}
+	fmt.Println("Automatically ignored changes:\n" + strings.Join(lines, "\n"))
+}
+
+// reportVerifyResult prints out the per resource diff, and returns a VerifyError if there is any diff.
+func reportVerifyResult(diffs []meta.ResourceDiff) error {
+	if len(diffs) == 0 {
+		fmt.Println("Verification: terraform plan shows no diff")
+		return nil
+	}
+	var lines []string
+	for _, diff := range diffs {
+		lines = append(lines, diff.String())
+	}
+	fmt.Fprintln(os.Stderr, "Verification:\n"+strings.Join(lines, "\n"))
+	return VerifyError{Diffs: diffs}
 }
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/Azure/aztfy/internal/test"
@@ -117,12 +116,14 @@ resource "azurerm_key_vault_key" "test" {
 `, d.RandomRgName(), d.RandomStringOfLength(8))
 }
 
+// getItems gets the data plane ids of the key vault items, which can be recorded into (or replayed from) the cassette
+// of the case via the client.RecorderModeEnvVar and client.RecorderCassetteEnvVar.
 func (CaseKeyVaultNestedItems) getItems(d test.Data) (keyId, secretId, certId string, err error) {
-	b, err := client.NewClientBuilder()
+	b, err := client.NewClientBuilder(client.AuthDefault)
 	if err != nil {
 		return "", "", "", err
 	}
-	subid := os.Getenv("ARM_SUBSCRIPTION_ID")
+	subid := d.SubscriptionId
 	ctx := context.Background()
 	{
 		client, err := b.NewKeyvaultKeysClient(subid)
@@ -0,0 +1,45 @@
+package cases
+
+import (
+	"testing"
+
+	"github.com/Azure/aztfy/internal/client"
+	"github.com/Azure/aztfy/internal/resmap"
+	"github.com/Azure/aztfy/internal/test"
+	"github.com/Azure/aztfy/internal/tfaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaseKeyVaultNestedItemsReplay(t *testing.T) {
+	const (
+		rgId     = "/subscriptions/" + FixtureSubscriptionId + "/resourceGroups/aztfy-rg-aztfyrec"
+		vaultId  = rgId + "/providers/Microsoft.KeyVault/vaults/aztfy-test-aztfyrec"
+		vaultUri = "https://aztfy-test-aztfyrec.vault.azure.net"
+	)
+	cassette, err := CassetteFile("key_vault_nested_items")
+	require.NoError(t, err)
+	t.Setenv(client.RecorderModeEnvVar, client.RecorderModeReplay)
+	t.Setenv(client.RecorderCassetteEnvVar, cassette)
+
+	c, d := CaseKeyVaultNestedItems{}, test.NewRecordedData(FixtureSubscriptionId)
+
+	ids, err := c.AzureResourceIds(d)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		rgId,
+		vaultId,
+		vaultId + "/keys/key-aztfyrec",
+		vaultId + "/secrets/secret-aztfyrec",
+		vaultId + "/certificates/cert-aztfyrec",
+	}, ids)
+
+	m, err := c.ResourceMapping(d)
+	require.NoError(t, err)
+	require.Equal(t, resmap.ResourceMapping{
+		rgId:    tfaddr.TFAddr{Type: "azurerm_resource_group", Name: "test"},
+		vaultId: tfaddr.TFAddr{Type: "azurerm_key_vault", Name: "test"},
+		vaultUri + "/keys/key-aztfyrec/6d9f5c1a3b2e4f7a8c0d1e2f3a4b5c6d":          tfaddr.TFAddr{Type: "azurerm_key_vault_key", Name: "test"},
+		vaultUri + "/secrets/secret-aztfyrec/0a1b2c3d4e5f60718293a4b5c6d7e8f9":    tfaddr.TFAddr{Type: "azurerm_key_vault_secret", Name: "test"},
+		vaultUri + "/certificates/cert-aztfyrec/f9e8d7c6b5a4938271605f4e3d2c1b0a": tfaddr.TFAddr{Type: "azurerm_key_vault_certificate", Name: "test"},
+	}, m)
+}
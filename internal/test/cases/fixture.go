@@ -0,0 +1,131 @@
+package cases
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/Azure/aztfy/internal/client"
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// The subscription and resource group that the fixtures are recorded against (with the real ones being replaced).
+// The subscription id is the same one that the recorder rewrites the real ones to in the cassettes.
+const (
+	FixtureSubscriptionId    = client.RecordedSubscriptionId
+	FixtureResourceGroupName = "aztfy-golden"
+)
+
+// The files of a fixture, under the testdata/<fixture name> directory.
+const (
+	fixtureArmTemplateFile   = "armtemplate.json"
+	fixtureAztftResultsFile  = "aztft.json"
+	fixtureProviderStateFile = "state.json"
+	fixtureGoldenFile        = "main.tf"
+)
+
+// The directory under testdata that contains the cassettes, rather than a fixture.
+const cassettesDir = "cassettes"
+
+// Fixture is the recorded inputs of a case, which allow it to be run offline (i.e. without Azure or Terraform),
+// and the golden output of the generated config.
+type Fixture struct {
+	// ArmTemplateFile is the path to the ARM template exported from the resource group.
+	ArmTemplateFile string
+
+	// AztftResults are the results of querying the TF resource type and id, keyed by the Azure resource id.
+	AztftResults map[string]AztftResult
+
+	// ProviderState is the state of the imported resources, as output by "terraform show -json".
+	ProviderState *tfjson.State
+
+	// GoldenFile is the path to the expected config generated from the fixture.
+	GoldenFile string
+}
+
+type AztftResult struct {
+	ResourceTypes []string `json:"resource_types"`
+	ResourceIds   []string `json:"resource_ids"`
+}
+
+// FixtureNames returns the names of the recorded fixtures, i.e. the directories under testdata (except the one of the
+// cassettes), in order. Recording a fixture for a case is all it takes to have it run offline.
+func FixtureNames() ([]string, error) {
+	dir, err := testdataDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading directory %s: %v", dir, err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == cassettesDir {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	return names, nil
+}
+
+// LoadFixture loads the fixture of the name.
+func LoadFixture(name string) (*Fixture, error) {
+	dir, err := testdataDir()
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Join(dir, name)
+
+	fixture := &Fixture{
+		ArmTemplateFile: filepath.Join(dir, fixtureArmTemplateFile),
+		GoldenFile:      filepath.Join(dir, fixtureGoldenFile),
+	}
+	b, err := os.ReadFile(filepath.Join(dir, fixtureAztftResultsFile))
+	if err != nil {
+		return nil, fmt.Errorf("reading the aztft results of fixture %s: %v", name, err)
+	}
+	if err := json.Unmarshal(b, &fixture.AztftResults); err != nil {
+		return nil, fmt.Errorf("unmarshalling the aztft results of fixture %s: %v", name, err)
+	}
+	b, err = os.ReadFile(filepath.Join(dir, fixtureProviderStateFile))
+	if err != nil {
+		return nil, fmt.Errorf("reading the provider state of fixture %s: %v", name, err)
+	}
+	if err := json.Unmarshal(b, &fixture.ProviderState); err != nil {
+		return nil, fmt.Errorf("unmarshalling the provider state of fixture %s: %v", name, err)
+	}
+	return fixture, nil
+}
+
+// CassetteFile returns the path of the cassette of the name, which records the interactions with Azure of the case
+// (see client.Recorder), with the data returned by test.NewRecordedData.
+func CassetteFile(name string) (string, error) {
+	dir, err := testdataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, cassettesDir, name+".json"), nil
+}
+
+func testdataDir() (string, error) {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		return "", fmt.Errorf("locating the testdata directory")
+	}
+	return filepath.Join(filepath.Dir(file), "testdata"), nil
+}
+
+// QueryTypeAndId looks up the recorded aztft result of the Azure resource id. It has the same signature as
+// aztft.QueryTypeAndId, so that it can replace the latter.
+func (f Fixture) QueryTypeAndId(id string, _ bool) ([]string, []string, error) {
+	for k, v := range f.AztftResults {
+		if strings.EqualFold(k, id) {
+			return v.ResourceTypes, v.ResourceIds, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("no aztft result recorded for %s", id)
+}
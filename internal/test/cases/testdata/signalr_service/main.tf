@@ -0,0 +1,25 @@
+resource "azurerm_resource_group" "res-0" {
+  name     = "aztfy-golden"
+  location = "westeurope"
+}
+resource "azurerm_signalr_service" "res-1" {
+  name                = "aztfy-golden-signalr"
+  resource_group_name = "aztfy-golden"
+  location            = "westeurope"
+
+  connectivity_logs_enabled = false
+  messaging_logs_enabled    = false
+  service_mode              = "Default"
+
+  cors {
+    allowed_origins = ["*"]
+  }
+  sku {
+    name     = "Free_F1"
+    capacity = 1
+  }
+
+  depends_on = [
+    azurerm_resource_group.res-0,
+  ]
+}
@@ -0,0 +1,32 @@
+resource "azurerm_resource_group" "res-0" {
+  name     = "aztfy-golden"
+  location = "westeurope"
+}
+resource "azurerm_storage_account" "res-1" {
+  name                = "aztfygolden"
+  resource_group_name = "aztfy-golden"
+  location            = "westeurope"
+
+  account_replication_type = "LRS"
+  account_tier             = "Standard"
+
+  account_kind = "StorageV2"
+
+  depends_on = [
+    azurerm_resource_group.res-0,
+  ]
+}
+resource "azurerm_storage_share" "res-3" {
+  name = "aztfygolden"
+
+  quota                = 5
+  storage_account_name = "aztfygolden"
+
+  access_tier      = "TransactionOptimized"
+  enabled_protocol = "SMB"
+
+  depends_on = [
+    # Depending on "/subscriptions/00000000-0000-0000-0000-000000000000/resourceGroups/aztfy-golden/providers/Microsoft.Storage/storageAccounts/aztfygolden/fileServices/default", which is not imported by Terraform.
+    azurerm_storage_account.res-1,
+  ]
+}
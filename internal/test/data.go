@@ -1,15 +1,38 @@
 package test
 
-import "os"
+import (
+	"os"
+
+	"github.com/Azure/aztfy/internal/client"
+)
 
 type Data struct {
 	Rd
 	SubscriptionId string
 }
 
+// NewData returns the data of the random values, unless the interactions with Azure are being recorded, in which case
+// the fixed values of NewRecordedData are used, so that the recorded cassettes can be replayed. The real subscription
+// id is still used for recording, which the recorder rewrites to client.RecordedSubscriptionId in the cassettes.
 func NewData() Data {
+	subscriptionId := os.Getenv("ARM_SUBSCRIPTION_ID")
+	if os.Getenv(client.RecorderModeEnvVar) == client.RecorderModeRecord {
+		return NewRecordedData(subscriptionId)
+	}
 	return Data{
 		Rd:             NewRd(),
-		SubscriptionId: os.Getenv("ARM_SUBSCRIPTION_ID"),
+		SubscriptionId: subscriptionId,
+	}
+}
+
+// NewRecordedData returns the data of the fixed random values, which the cassettes of the cases are recorded with,
+// so that the cases can be replayed.
+func NewRecordedData(subscriptionId string) Data {
+	return Data{
+		Rd: Rd{
+			num: 221018000000000000,
+			str: "aztfyrecordedcases",
+		},
+		SubscriptionId: subscriptionId,
 	}
 }
@@ -14,7 +14,7 @@ type Item struct {
 
 func (i Item) Title() string {
 	switch {
-	case i.v.ValidateError != nil:
+	case i.v.ValidateError != nil, i.v.ExportError != nil:
 		return common.WarningEmoji + i.v.ResourceID
 	case i.v.ImportError != nil:
 		return common.ErrorEmoji + i.v.ResourceID
@@ -33,13 +33,16 @@ func (i Item) Description() string {
 		return i.textinput.View()
 	}
 	if i.v.Skip() {
+		if i.v.ExportError != nil {
+			return "(Skip) failed to export: " + i.v.ExportError.Error()
+		}
 		return "(Skip)"
 	}
 	return i.textinput.Value()
 }
 
 func (i Item) FilterValue() string {
-	if i.v.ValidateError == nil && i.v.ImportError == nil && !i.v.Imported && !i.v.IsRecommended {
+	if i.v.ValidateError == nil && i.v.ExportError == nil && i.v.ImportError == nil && !i.v.Imported && !i.v.IsRecommended {
 		return i.v.ResourceID
 	}
 	return " " + i.v.ResourceID
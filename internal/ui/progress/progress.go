@@ -39,10 +39,23 @@ func (m Model) Init() tea.Cmd {
 	}
 
 	return tea.Batch(
-		aztfyclient.ImportOneItem(m.c, m.l[m.idx]),
+		m.importOneItem(),
 	)
 }
 
+// importOneItem imports the current item, unless it is blocked by its dependency that failed to import (or is blocked).
+func (m Model) importOneItem() tea.Cmd {
+	item := m.l[m.idx]
+	item.BlockedReason = ""
+	if dep, ok := m.l.FailedDependency(item); ok && !item.Skip() && !item.Imported {
+		item.BlockedReason = fmt.Sprintf("its dependency %s (%s) is not imported", dep.ResourceID, dep.TFAddr)
+		return func() tea.Msg {
+			return aztfyclient.ImportOneItemDoneMsg{Item: item}
+		}
+	}
+	return aztfyclient.ImportOneItem(m.c, item)
+}
+
 func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
@@ -63,7 +76,7 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		res := result{
 			item: msg.Item,
 		}
-		if item.ImportError != nil {
+		if item.ImportError != nil || item.BlockedReason != "" {
 			res.emoji = common.WarningEmoji
 		} else {
 			res.emoji = common.RandomHappyEmoji()
@@ -80,7 +93,7 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			cmds = append(cmds, cmd)
 			return m, tea.Batch(cmds...)
 		}
-		cmd = aztfyclient.ImportOneItem(m.c, m.l[m.idx])
+		cmd = m.importOneItem()
 		cmds = append(cmds, cmd)
 		return m, tea.Batch(cmds...)
 	default:
@@ -106,8 +119,12 @@ func (m Model) View() string {
 			s += "...\n"
 		} else {
 			switch {
+			case res.item.Skip() && res.item.ExportError != nil:
+				s += fmt.Sprintf("%s %s skipped (failed to export: %v)\n", res.emoji, res.item.ResourceID, res.item.ExportError)
 			case res.item.Skip():
 				s += fmt.Sprintf("%s %s skipped\n", res.emoji, res.item.ResourceID)
+			case res.item.BlockedReason != "":
+				s += fmt.Sprintf("%s %s import blocked: %s\n", res.emoji, res.item.ResourceID, res.item.BlockedReason)
 			default:
 				if res.item.ImportError == nil {
 					s += fmt.Sprintf("%s %s import successfully\n", res.emoji, res.item.ResourceID)
@@ -52,6 +52,7 @@ func (s status) String() string {
 		"importing",
 		"import error message",
 		"generating Terraform configuration",
+		"exporting resource mapping",
 		"summary",
 		"quitting",
 		"error",
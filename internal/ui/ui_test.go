@@ -0,0 +1,237 @@
+package ui
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/aztfy/internal/config"
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden snapshots")
+
+// cmdTimeout is how long to wait for a command to return its message. The commands that don't return in time are the
+// timers (e.g. the one clearing the status message of the list), which are discarded.
+const cmdTimeout = 500 * time.Millisecond
+
+var windowSize = tea.WindowSizeMsg{Width: 120, Height: 30}
+
+type uiStep struct {
+	name string
+	// The scripted input messages, e.g. the keys.
+	msgs []tea.Msg
+	// The expected status after the messages (and the messages resulted from them) are processed.
+	status status
+}
+
+// uiDriver drives the model headlessly, by processing the messages and running the commands synchronously.
+type uiDriver struct {
+	m    model
+	quit bool
+}
+
+func newUIDriver(t *testing.T, scenarioFile string) *uiDriver {
+	m, err := newModel(config.RgConfig{
+		CommonConfig: config.CommonConfig{
+			MockClient:       true,
+			MockScenarioFile: scenarioFile,
+		},
+		ResourceGroupName: "rg",
+	})
+	require.NoError(t, err)
+	d := &uiDriver{m: *m}
+	d.run(d.m.Init())
+	return d
+}
+
+func (d *uiDriver) send(msg tea.Msg) {
+	var queue []tea.Msg
+	queue = append(queue, msg)
+	for len(queue) != 0 {
+		msg := queue[0]
+		queue = queue[1:]
+		if msg == tea.Quit() {
+			d.quit = true
+			continue
+		}
+		newModel, cmd := d.m.Update(msg)
+		d.m = newModel.(model)
+		queue = append(queue, d.exec(cmd)...)
+	}
+}
+
+func (d *uiDriver) run(cmd tea.Cmd) {
+	for _, msg := range d.exec(cmd) {
+		d.send(msg)
+	}
+}
+
+// exec runs the command, and returns the resulting messages in order. The batched commands are expanded, while the
+// animation messages (i.e. the spinner ticks and the progress bar frames) are dropped to keep the views deterministic.
+func (d *uiDriver) exec(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	ch := make(chan tea.Msg, 1)
+	go func() { ch <- cmd() }()
+	var msg tea.Msg
+	select {
+	case msg = <-ch:
+	case <-time.After(cmdTimeout):
+		return nil
+	}
+
+	switch msg.(type) {
+	case nil, spinner.TickMsg, progress.FrameMsg:
+		return nil
+	}
+	// The batch message of bubbletea is not exported, which is a slice of commands.
+	if v := reflect.ValueOf(msg); v.Kind() == reflect.Slice && v.Type().Elem() == reflect.TypeOf(tea.Cmd(nil)) {
+		var msgs []tea.Msg
+		for i := 0; i < v.Len(); i++ {
+			msgs = append(msgs, d.exec(v.Index(i).Interface().(tea.Cmd))...)
+		}
+		return msgs
+	}
+	return []tea.Msg{msg}
+}
+
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// snapshot returns the view, with the ANSI escape sequences and the trailing spaces of each line stripped.
+func (d *uiDriver) snapshot() string {
+	lines := strings.Split(ansiPattern.ReplaceAllString(d.m.View(), ""), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func keyRunes(s string) tea.Msg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+// runUISteps runs the steps, asserts the status after each step, and compares the view snapshots of all the steps
+// against the golden file.
+func runUISteps(t *testing.T, name, scenarioFile string, steps []uiStep) *uiDriver {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	lipgloss.SetColorProfile(termenv.Ascii)
+
+	d := newUIDriver(t, scenarioFile)
+	var snapshots []string
+	for i, step := range steps {
+		for _, msg := range step.msgs {
+			d.send(msg)
+		}
+		require.Equal(t, step.status.String(), d.m.status.String(), "step %d: %s", i, step.name)
+		snapshots = append(snapshots, fmt.Sprintf("=== %d: %s (%s)\n%s\n", i, step.name, d.m.status, d.snapshot()))
+	}
+	actual := strings.Join(snapshots, "\n")
+
+	goldenFile := filepath.Join("testdata", name+".golden")
+	if *update {
+		require.NoError(t, os.WriteFile(goldenFile, []byte(actual), 0644))
+		return d
+	}
+	expect, err := os.ReadFile(goldenFile)
+	require.NoError(t, err, "run with -update to create the golden file")
+	require.Equal(t, string(expect), actual)
+	return d
+}
+
+func TestUIImportErrorBackToList(t *testing.T) {
+	d := runUISteps(t, "import_error", filepath.Join("testdata", "scenario_import_error.yaml"), []uiStep{
+		{
+			name:   "list resources",
+			msgs:   []tea.Msg{windowSize},
+			status: statusBuildingImportList,
+		},
+		{
+			name:   "import fails on the virtual network",
+			msgs:   []tea.Msg{keyRunes("w")},
+			status: statusBuildingImportList,
+		},
+		{
+			name:   "show the import error",
+			msgs:   []tea.Msg{keyRunes("e")},
+			status: statusImportErrorMsg,
+		},
+		{
+			name:   "back to the list",
+			msgs:   []tea.Msg{keyRunes("x")},
+			status: statusBuildingImportList,
+		},
+		{
+			name:   "skip the virtual network and import again",
+			msgs:   []tea.Msg{tea.KeyMsg{Type: tea.KeyDelete}, keyRunes("w")},
+			status: statusSummary,
+		},
+		{
+			name:   "quit",
+			msgs:   []tea.Msg{keyRunes("x")},
+			status: statusSummary,
+		},
+	})
+	require.True(t, d.quit)
+}
+
+func TestUIGenerateError(t *testing.T) {
+	runUISteps(t, "generate_error", filepath.Join("testdata", "scenario_generate_error.yaml"), []uiStep{
+		{
+			name:   "list resources",
+			msgs:   []tea.Msg{windowSize},
+			status: statusBuildingImportList,
+		},
+		{
+			name:   "import and fail to generate",
+			msgs:   []tea.Msg{keyRunes("w")},
+			status: statusError,
+		},
+	})
+}
+
+func TestUINothingToImport(t *testing.T) {
+	d := runUISteps(t, "nothing_to_import", filepath.Join("testdata", "scenario_nothing_to_import.yaml"), []uiStep{
+		{
+			name:   "list resources",
+			msgs:   []tea.Msg{windowSize},
+			status: statusBuildingImportList,
+		},
+		{
+			name:   "import with all resources skipped",
+			msgs:   []tea.Msg{keyRunes("w")},
+			status: statusBuildingImportList,
+		},
+		{
+			name:   "set the resource type",
+			msgs:   []tea.Msg{tea.KeyMsg{Type: tea.KeyEnter}, keyRunes("azurerm_resource_group.test"), tea.KeyMsg{Type: tea.KeyEnter}},
+			status: statusBuildingImportList,
+		},
+		{
+			name:   "import",
+			msgs:   []tea.Msg{keyRunes("w")},
+			status: statusSummary,
+		},
+		{
+			name:   "interrupt",
+			msgs:   []tea.Msg{tea.KeyMsg{Type: tea.KeyCtrlC}},
+			status: statusQuitting,
+		},
+	})
+	require.True(t, d.quit)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -16,7 +17,10 @@ import (
 	"github.com/magodo/tfadd/providers/azurerm"
 
 	"github.com/Azure/aztfy/internal"
+	"github.com/Azure/aztfy/internal/client"
 	"github.com/Azure/aztfy/internal/config"
+	"github.com/Azure/aztfy/internal/meta"
+	"github.com/Azure/aztfy/internal/resmap"
 	"github.com/Azure/aztfy/internal/ui"
 	azlog "github.com/Azure/azure-sdk-for-go/sdk/azcore/log"
 	"github.com/urfave/cli/v2"
@@ -25,31 +29,107 @@ import (
 func main() {
 	var (
 		// common flags
-		flagSubscriptionId string
-		flagOutputDir      string
-		flagOverwrite      bool
-		flagAppend         bool
-		flagDevProvider    bool
-		flagBackendType    string
-		flagBackendConfig  cli.StringSlice
+		flagSubscriptionId    string
+		flagAuth              string
+		flagOutputDir         string
+		flagOverwrite         bool
+		flagAppend            bool
+		flagDevProvider       bool
+		flagBackendType       string
+		flagBackendConfig     cli.StringSlice
+		flagAnnotate          bool
+		flagTransformers      cli.StringSlice
+		flagRewriteRules      string
+		flagVerify            bool
+		flagAutoIgnoreChanges int
+		flagDataSource        bool
 
 		// common flags (hidden)
-		hflagLogPath string
+		hflagLogPath      string
+		hflagMockClient   bool
+		hflagMockScenario string
 
 		// rg-only flags
 		flagBatchMode   bool
 		flagContinue    bool
 		flagMappingFile string
 		flagPattern     string
-
-		// rg-only flags (hidden)
-		hflagMockClient bool
+		flagGraph       bool
+		flagManagedRes  string
+		flagDiscovery   string
+		flagExportChunk int
+		flagArmTemplate string
+		flagExternalDS  bool
 
 		// res-only flags
 		flagName string
 	)
 
+	// The authentication method, shared by all the commands talking to Azure.
+	authFlag := &cli.StringFlag{
+		Name:        "auth",
+		EnvVars:     []string{"AZTFY_AUTH"},
+		Usage:       fmt.Sprintf("The authentication method, can be one of %v. Other than %q, the same method is used by the provider", client.PossibleAuthValues(), client.AuthDefault),
+		Value:       client.AuthDefault,
+		Destination: &flagAuth,
+	}
+
+	// The flags below are shared by multiple commands, they are defined once to keep them consistent.
+	subscriptionIdFlag := &cli.StringFlag{
+		Name: "subscription-id",
+		// Honor the "ARM_SUBSCRIPTION_ID" as is used by the AzureRM provider, for easier use.
+		EnvVars:     []string{"AZTFY_SUBSCRIPTION_ID", "ARM_SUBSCRIPTION_ID"},
+		Aliases:     []string{"s"},
+		Usage:       "The subscription id",
+		Destination: &flagSubscriptionId,
+	}
+	outputDirFlag := &cli.StringFlag{
+		Name:    "output-dir",
+		EnvVars: []string{"AZTFY_OUTPUT_DIR"},
+		Aliases: []string{"o"},
+		Usage:   "The output directory",
+		Value: func() string {
+			dir, _ := os.Getwd()
+			return dir
+		}(),
+		Destination: &flagOutputDir,
+	}
+	resourceMappingFlag := &cli.StringFlag{
+		Name:        "resource-mapping",
+		EnvVars:     []string{"AZTFY_RESOURCE_MAPPING"},
+		Aliases:     []string{"m"},
+		Usage:       "The resource mapping file, or a directory containing annotated Terraform configurations generated by aztfy (via `--annotate`)",
+		Destination: &flagMappingFile,
+	}
+	namePatternFlag := &cli.StringFlag{
+		Name:        "name-pattern",
+		EnvVars:     []string{"AZTFY_NAME_PATTERN"},
+		Aliases:     []string{"p"},
+		Usage:       `The pattern of the resource name. The semantic of a pattern is the same as Go's os.CreateTemp()`,
+		Value:       "res-",
+		Destination: &flagPattern,
+	}
+	logPathFlag := &cli.StringFlag{
+		Name:        "log-path",
+		EnvVars:     []string{"AZTFY_LOG_PATH"},
+		Usage:       "The path to store the log",
+		Hidden:      true,
+		Destination: &hflagLogPath,
+	}
+
 	commonFlagsCheck := func() error {
+		if err := authFlagCheck(flagAuth); err != nil {
+			return err
+		}
+		if hflagMockScenario != "" && !hflagMockClient {
+			return fmt.Errorf("`--mock-scenario` must be used together with `--mock-client`")
+		}
+		if flagAutoIgnoreChanges < 0 {
+			return fmt.Errorf("`--auto-ignore-changes` must not be negative")
+		}
+		if flagDataSource && flagAutoIgnoreChanges != 0 {
+			return fmt.Errorf("`--data-source` conflicts with `--auto-ignore-changes`")
+		}
 		if flagAppend {
 			if flagBackendType != "local" {
 				return fmt.Errorf("`--append` only works for local backend")
@@ -62,25 +142,9 @@ func main() {
 	}
 
 	commonFlags := []cli.Flag{
-		&cli.StringFlag{
-			Name: "subscription-id",
-			// Honor the "ARM_SUBSCRIPTION_ID" as is used by the AzureRM provider, for easier use.
-			EnvVars:     []string{"AZTFY_SUBSCRIPTION_ID", "ARM_SUBSCRIPTION_ID"},
-			Aliases:     []string{"s"},
-			Usage:       "The subscription id",
-			Destination: &flagSubscriptionId,
-		},
-		&cli.StringFlag{
-			Name:    "output-dir",
-			EnvVars: []string{"AZTFY_OUTPUT_DIR"},
-			Aliases: []string{"o"},
-			Usage:   "The output directory",
-			Value: func() string {
-				dir, _ := os.Getwd()
-				return dir
-			}(),
-			Destination: &flagOutputDir,
-		},
+		subscriptionIdFlag,
+		authFlag,
+		outputDirFlag,
 		&cli.BoolFlag{
 			Name:        "overwrite",
 			EnvVars:     []string{"AZTFY_OVERWRITE"},
@@ -113,14 +177,90 @@ func main() {
 			Usage:       "The Terraform backend config",
 			Destination: &flagBackendConfig,
 		},
+		&cli.BoolFlag{
+			Name:        "annotate",
+			EnvVars:     []string{"AZTFY_ANNOTATE"},
+			Usage:       "Add provenance annotations (e.g. the Azure resource id, the aztfy version) as comments on each generated resource block",
+			Destination: &flagAnnotate,
+		},
+		&cli.StringSliceFlag{
+			Name:        "transformer",
+			EnvVars:     []string{"AZTFY_TRANSFORMER"},
+			Usage:       "The path to an external executable that transforms each generated resource block. Can be specified multiple times, which run in order",
+			Destination: &flagTransformers,
+		},
+		&cli.StringFlag{
+			Name:        "rewrite-rules",
+			EnvVars:     []string{"AZTFY_REWRITE_RULES"},
+			Usage:       "The rewrite rules file, which declares how to rewrite the generated resource blocks per resource type/id",
+			Destination: &flagRewriteRules,
+		},
+		&cli.BoolFlag{
+			Name:        "verify",
+			EnvVars:     []string{"AZTFY_VERIFY"},
+			Usage:       "Verify the generated workspace via terraform plan, and exit with code 2 if there is any diff (batch mode only)",
+			Destination: &flagVerify,
+		},
+		&cli.IntFlag{
+			Name:        "auto-ignore-changes",
+			EnvVars:     []string{"AZTFY_AUTO_IGNORE_CHANGES"},
+			Usage:       "The max iterations of running terraform plan and adding the attributes that still have in-place diff to the lifecycle.ignore_changes (0 means disabled, batch mode only)",
+			Destination: &flagAutoIgnoreChanges,
+		},
+		&cli.BoolFlag{
+			Name:        "data-source",
+			EnvVars:     []string{"AZTFY_DATA_SOURCE"},
+			Usage:       "Generate data sources (that are validated via terraform plan) instead of importing the resources, for referencing the resources without managing them (batch mode only)",
+			Destination: &flagDataSource,
+		},
 
 		// Hidden flags
+		logPathFlag,
+		&cli.BoolFlag{
+			Name:        "mock-client",
+			EnvVars:     []string{"AZTFY_MOCK_CLIENT"},
+			Usage:       "Whether to mock the client. This is for testing UI",
+			Hidden:      true,
+			Destination: &hflagMockClient,
+		},
 		&cli.StringFlag{
-			Name:        "log-path",
-			EnvVars:     []string{"AZTFY_LOG_PATH"},
-			Usage:       "The path to store the log",
+			Name:        "mock-scenario",
+			EnvVars:     []string{"AZTFY_MOCK_SCENARIO"},
+			Usage:       "The scenario file (YAML or JSON) that drives the mock client, e.g. the resources, the import errors and the latencies",
 			Hidden:      true,
-			Destination: &hflagLogPath,
+			Destination: &hflagMockScenario,
+		},
+	}
+
+	// discoveryOnlyFlagsCheck checks the flags of the commands that only discover the resources in a resource group,
+	// without importing them, which don't have the commonFlags.
+	discoveryOnlyFlagsCheck := func() error {
+		if err := authFlagCheck(flagAuth); err != nil {
+			return err
+		}
+		return discoveryFlagCheck(flagDiscovery, flagExportChunk)
+	}
+
+	// The flags to discover the resources in a resource group, shared by the commands working on a resource group.
+	discoveryFlags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "managed-resources",
+			EnvVars:     []string{"AZTFY_MANAGED_RESOURCES"},
+			Usage:       "The managed resource table file, which overrides the builtin table (per ARM resource type) of the resources exclusively managed by other resources, that are missing from the ARM template",
+			Destination: &flagManagedRes,
+		},
+		&cli.StringFlag{
+			Name:        "discovery",
+			EnvVars:     []string{"AZTFY_DISCOVERY"},
+			Usage:       fmt.Sprintf("The strategy to discover the resources in the resource group, can be one of %v. %q exports the ARM template, and falls back to %q (i.e. listing the resources) if the export fails", meta.PossibleDiscoveryValues(), meta.DiscoveryAuto, meta.DiscoveryList),
+			Value:       meta.DiscoveryAuto,
+			Destination: &flagDiscovery,
+		},
+		&cli.IntFlag{
+			Name:        "export-chunk-size",
+			EnvVars:     []string{"AZTFY_EXPORT_CHUNK_SIZE"},
+			Usage:       "Export the ARM template in chunks of at most this amount of resources listed in the resource group, and merge them, to avoid hitting the export limits on large resource groups (0 means exporting all at once)",
+			Destination: &flagExportChunk,
 		},
 	}
 
@@ -143,13 +283,7 @@ func main() {
 						Usage:       "Batch mode (i.e. Non-interactive mode)",
 						Destination: &flagBatchMode,
 					},
-					&cli.StringFlag{
-						Name:        "resource-mapping",
-						EnvVars:     []string{"AZTFY_RESOURCE_MAPPING"},
-						Aliases:     []string{"m"},
-						Usage:       "The resource mapping file",
-						Destination: &flagMappingFile,
-					},
+					resourceMappingFlag,
 					&cli.BoolFlag{
 						Name:        "continue",
 						EnvVars:     []string{"AZTFY_CONTINUE"},
@@ -157,24 +291,26 @@ func main() {
 						Usage:       "Whether continue on import error (batch mode only)",
 						Destination: &flagContinue,
 					},
+					namePatternFlag,
+					&cli.BoolFlag{
+						Name:        "graph",
+						EnvVars:     []string{"AZTFY_GRAPH"},
+						Usage:       fmt.Sprintf("Export the dependency graph of the resources to the output directory, as %s and %s (batch mode only)", meta.GraphDOTFileName, meta.GraphJSONFileName),
+						Destination: &flagGraph,
+					},
 					&cli.StringFlag{
-						Name:        "name-pattern",
-						EnvVars:     []string{"AZTFY_NAME_PATTERN"},
-						Aliases:     []string{"p"},
-						Usage:       `The pattern of the resource name. The semantic of a pattern is the same as Go's os.CreateTemp()`,
-						Value:       "res-",
-						Destination: &flagPattern,
+						Name:        "arm-template",
+						EnvVars:     []string{"AZTFY_ARM_TEMPLATE"},
+						Usage:       fmt.Sprintf("The ARM template file (e.g. the %s exported via the `export-template` command) to read the resources from, instead of discovering them from Azure", meta.ArmTemplateFileName),
+						Destination: &flagArmTemplate,
 					},
-
-					// Hidden flags
 					&cli.BoolFlag{
-						Name:        "mock-client",
-						EnvVars:     []string{"AZTFY_MOCK_CLIENT"},
-						Usage:       "Whether to mock the client. This is for testing UI",
-						Hidden:      true,
-						Destination: &hflagMockClient,
+						Name:        "external-data-sources",
+						EnvVars:     []string{"AZTFY_EXTERNAL_DATA_SOURCES"},
+						Usage:       "Generate data sources for the resources that are depended on but not imported (e.g. in other resource groups), and reference them instead of the hard-coded resource ids",
+						Destination: &flagExternalDS,
 					},
-				}, commonFlags...),
+				}, append(discoveryFlags, commonFlags...)...),
 				Action: func(c *cli.Context) error {
 					if err := commonFlagsCheck(); err != nil {
 						return err
@@ -188,6 +324,24 @@ func main() {
 					if flagContinue && !flagBatchMode {
 						return fmt.Errorf("`--continue` must be used together with `--batch`")
 					}
+					if flagVerify && !flagBatchMode {
+						return fmt.Errorf("`--verify` must be used together with `--batch`")
+					}
+					if flagAutoIgnoreChanges != 0 && !flagBatchMode {
+						return fmt.Errorf("`--auto-ignore-changes` must be used together with `--batch`")
+					}
+					if flagDataSource && !flagBatchMode {
+						return fmt.Errorf("`--data-source` must be used together with `--batch`")
+					}
+					if flagGraph && !flagBatchMode {
+						return fmt.Errorf("`--graph` must be used together with `--batch`")
+					}
+					if err := discoveryFlagCheck(flagDiscovery, flagExportChunk); err != nil {
+						return err
+					}
+					if flagArmTemplate != "" && (c.IsSet("discovery") || c.IsSet("export-chunk-size")) {
+						return fmt.Errorf("`--arm-template` conflicts with `--discovery` and `--export-chunk-size`")
+					}
 
 					rg := c.Args().First()
 
@@ -202,6 +356,14 @@ func main() {
 					// - Env variable: ARM_SUBSCRIPTION_ID
 					// - Output of azure cli, the current active subscription
 					subscriptionId := flagSubscriptionId
+					if subscriptionId == "" && hflagMockClient {
+						// The mock client never talks to Azure, so there is no need to look up the subscription id.
+						subscriptionId = mockSubscriptionId
+					}
+					if subscriptionId == "" && flagArmTemplate != "" {
+						// The resources are read from the ARM template file, don't talk to Azure (via azure cli) for the subscription id.
+						return fmt.Errorf("`--subscription-id` is required when `--arm-template` is specified")
+					}
 					if subscriptionId == "" {
 						var err error
 						subscriptionId, err = subscriptionIdFromCLI()
@@ -212,30 +374,43 @@ func main() {
 
 					// Initialize the config
 					cfg := config.RgConfig{
-						MockClient: hflagMockClient,
 						CommonConfig: config.CommonConfig{
-							SubscriptionId: subscriptionId,
-							OutputDir:      flagOutputDir,
-							Overwrite:      flagOverwrite,
-							Append:         flagAppend,
-							DevProvider:    flagDevProvider,
-							BackendType:    flagBackendType,
-							BackendConfig:  flagBackendConfig.Value(),
+							SubscriptionId:    subscriptionId,
+							Auth:              flagAuth,
+							OutputDir:         flagOutputDir,
+							Overwrite:         flagOverwrite,
+							Append:            flagAppend,
+							DevProvider:       flagDevProvider,
+							BackendType:       flagBackendType,
+							BackendConfig:     flagBackendConfig.Value(),
+							Annotate:          flagAnnotate,
+							AztfyVersion:      getVersion(),
+							Transformers:      flagTransformers.Value(),
+							RewriteRulesFile:  flagRewriteRules,
+							Verify:            flagVerify,
+							AutoIgnoreChanges: flagAutoIgnoreChanges,
+							DataSource:        flagDataSource,
+							MockClient:        hflagMockClient,
+							MockScenarioFile:  hflagMockScenario,
 						},
 					}
 
 					if flagMappingFile != "" {
-						b, err := os.ReadFile(flagMappingFile)
+						var err error
+						cfg.ResourceMapping, err = loadResourceMapping(flagMappingFile)
 						if err != nil {
-							return fmt.Errorf("reading mapping file %s: %v", flagMappingFile, err)
-						}
-						if err := json.Unmarshal(b, &cfg.ResourceMapping); err != nil {
-							return fmt.Errorf("unmarshalling the mapping file: %v", err)
+							return err
 						}
 					}
 					cfg.ResourceGroupName = rg
 					cfg.ResourceNamePattern = flagPattern
 					cfg.BatchMode = flagBatchMode
+					cfg.Graph = flagGraph
+					cfg.ManagedResourcesFile = flagManagedRes
+					cfg.Discovery = flagDiscovery
+					cfg.ExportChunkSize = flagExportChunk
+					cfg.ArmTemplateFile = flagArmTemplate
+					cfg.ExternalDataSources = flagExternalDS
 
 					// Run in batch mode
 					if cfg.BatchMode {
@@ -299,6 +474,10 @@ func main() {
 					// - Env variable: ARM_SUBSCRIPTION_ID
 					// - Output of azure cli, the current active subscription
 					subscriptionId := flagSubscriptionId
+					if subscriptionId == "" && hflagMockClient {
+						// The mock client never talks to Azure, so there is no need to look up the subscription id.
+						subscriptionId = mockSubscriptionId
+					}
 					if subscriptionId == "" {
 						var err error
 						subscriptionId, err = subscriptionIdFromCLI()
@@ -310,14 +489,24 @@ func main() {
 					// Initialize the config
 					cfg := config.ResConfig{
 						CommonConfig: config.CommonConfig{
-							SubscriptionId: subscriptionId,
-							OutputDir:      flagOutputDir,
-							Overwrite:      flagOverwrite,
-							Append:         flagAppend,
-							DevProvider:    flagDevProvider,
-							BatchMode:      true,
-							BackendType:    flagBackendType,
-							BackendConfig:  flagBackendConfig.Value(),
+							SubscriptionId:    subscriptionId,
+							Auth:              flagAuth,
+							OutputDir:         flagOutputDir,
+							Overwrite:         flagOverwrite,
+							Append:            flagAppend,
+							DevProvider:       flagDevProvider,
+							BatchMode:         true,
+							BackendType:       flagBackendType,
+							BackendConfig:     flagBackendConfig.Value(),
+							Annotate:          flagAnnotate,
+							AztfyVersion:      getVersion(),
+							Transformers:      flagTransformers.Value(),
+							RewriteRulesFile:  flagRewriteRules,
+							Verify:            flagVerify,
+							AutoIgnoreChanges: flagAutoIgnoreChanges,
+							DataSource:        flagDataSource,
+							MockClient:        hflagMockClient,
+							MockScenarioFile:  hflagMockScenario,
 						},
 						ResourceId:   resId,
 						ResourceName: flagName,
@@ -326,6 +515,130 @@ func main() {
 					return internal.ResourceImport(cfg)
 				},
 			},
+			{
+				Name:      "graph",
+				Usage:     "Exporting the dependency graph of the resources in a resource group, without importing them",
+				UsageText: "aztfy graph [option] <resource group name>",
+				Flags: append([]cli.Flag{
+					subscriptionIdFlag,
+					authFlag,
+					outputDirFlag,
+					resourceMappingFlag,
+					namePatternFlag,
+
+					// Hidden flags
+					logPathFlag,
+				}, discoveryFlags...),
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return fmt.Errorf("No resource group specified")
+					}
+					if c.NArg() > 1 {
+						return fmt.Errorf("More than one resource groups specified")
+					}
+					if err := discoveryOnlyFlagsCheck(); err != nil {
+						return err
+					}
+
+					rg := c.Args().First()
+
+					// Initialize log
+					if err := initLog(hflagLogPath); err != nil {
+						return err
+					}
+
+					subscriptionId := flagSubscriptionId
+					if subscriptionId == "" {
+						var err error
+						subscriptionId, err = subscriptionIdFromCLI()
+						if err != nil {
+							return fmt.Errorf("retrieving subscription id from CLI: %v", err)
+						}
+					}
+
+					cfg := config.RgConfig{
+						CommonConfig: config.CommonConfig{
+							SubscriptionId: subscriptionId,
+							Auth:           flagAuth,
+							OutputDir:      flagOutputDir,
+							// Nothing is imported, so the output directory is not required to be empty.
+							Append:    true,
+							BatchMode: true,
+						},
+						ResourceGroupName:    rg,
+						ResourceNamePattern:  flagPattern,
+						ManagedResourcesFile: flagManagedRes,
+						Discovery:            flagDiscovery,
+						ExportChunkSize:      flagExportChunk,
+					}
+					if flagMappingFile != "" {
+						var err error
+						cfg.ResourceMapping, err = loadResourceMapping(flagMappingFile)
+						if err != nil {
+							return err
+						}
+					}
+
+					return internal.Graph(cfg)
+				},
+			},
+			{
+				Name:      "export-template",
+				Usage:     "Exporting the ARM template of a resource group, which can be used later via the `--arm-template` option of the `resource-group` command",
+				UsageText: "aztfy export-template [option] <resource group name>",
+				Flags: append([]cli.Flag{
+					subscriptionIdFlag,
+					authFlag,
+					outputDirFlag,
+
+					// Hidden flags
+					logPathFlag,
+				}, discoveryFlags...),
+				Action: func(c *cli.Context) error {
+					if c.NArg() == 0 {
+						return fmt.Errorf("No resource group specified")
+					}
+					if c.NArg() > 1 {
+						return fmt.Errorf("More than one resource groups specified")
+					}
+					if err := discoveryOnlyFlagsCheck(); err != nil {
+						return err
+					}
+
+					rg := c.Args().First()
+
+					// Initialize log
+					if err := initLog(hflagLogPath); err != nil {
+						return err
+					}
+
+					subscriptionId := flagSubscriptionId
+					if subscriptionId == "" {
+						var err error
+						subscriptionId, err = subscriptionIdFromCLI()
+						if err != nil {
+							return fmt.Errorf("retrieving subscription id from CLI: %v", err)
+						}
+					}
+
+					cfg := config.RgConfig{
+						CommonConfig: config.CommonConfig{
+							SubscriptionId: subscriptionId,
+							Auth:           flagAuth,
+							OutputDir:      flagOutputDir,
+							// Nothing is imported, so the output directory is not required to be empty.
+							Append:    true,
+							BatchMode: true,
+						},
+						ResourceGroupName:    rg,
+						ManagedResourcesFile: flagManagedRes,
+						Discovery:            flagDiscovery,
+						ExportChunkSize:      flagExportChunk,
+					}
+
+					return internal.ExportArmTemplate(cfg)
+				},
+			},
 		},
 	}
 
@@ -333,10 +646,65 @@ func main() {
 
 	if err := app.Run(os.Args); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		// Exit with a dedicated code for the diff shown in the verification, similar to "terraform plan -detailed-exitcode".
+		var verr internal.VerifyError
+		if errors.As(err, &verr) {
+			os.Exit(2)
+		}
 		os.Exit(1)
 	}
 }
 
+func discoveryFlagCheck(discovery string, exportChunkSize int) error {
+	if exportChunkSize < 0 {
+		return fmt.Errorf("`--export-chunk-size` must not be negative")
+	}
+	if exportChunkSize > 0 && discovery == meta.DiscoveryList {
+		return fmt.Errorf("`--export-chunk-size` conflicts with `--discovery=%s`", meta.DiscoveryList)
+	}
+	for _, v := range meta.PossibleDiscoveryValues() {
+		if discovery == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("`--discovery` must be one of %v", meta.PossibleDiscoveryValues())
+}
+
+func authFlagCheck(auth string) error {
+	for _, v := range client.PossibleAuthValues() {
+		if auth == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("`--auth` must be one of %v", client.PossibleAuthValues())
+}
+
+// loadResourceMapping loads the resource mapping from either a resource mapping file, or a directory containing
+// annotated Terraform configurations generated by aztfy.
+func loadResourceMapping(path string) (resmap.ResourceMapping, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("stating mapping file %s: %v", path, err)
+	}
+	if stat.IsDir() {
+		// Rebuild the resource mapping from the provenance annotations of the generated configurations.
+		m, err := meta.ResourceMappingFromAnnotation(path)
+		if err != nil {
+			return nil, fmt.Errorf("building resource mapping from annotations in %s: %v", path, err)
+		}
+		return m, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading mapping file %s: %v", path, err)
+	}
+	var m resmap.ResourceMapping
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("unmarshalling the mapping file: %v", err)
+	}
+	return m, nil
+}
+
 func initLog(path string) error {
 	log.SetOutput(io.Discard)
 	if path != "" {
@@ -356,6 +724,9 @@ func initLog(path string) error {
 	return nil
 }
 
+// mockSubscriptionId is the subscription id used by the mock client, when it is not specified.
+const mockSubscriptionId = "00000000-0000-0000-0000-000000000000"
+
 func subscriptionIdFromCLI() (string, error) {
 	var stderr bytes.Buffer
 	var stdout bytes.Buffer